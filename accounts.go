@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Number of senders listed by the accounts command
+const accountSenders = 50
+
+// Handles the 'accounts' command, which combines the saved scans of every
+// profile in the config file into one view, showing which accounts each
+// sender appears in. Profiles which have not been scanned yet are skipped
+func runAccountsCommand(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: accounts")
+	}
+	config, err := loadConfig(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	merged := make(map[string]*SenderStats)
+	var order []string
+	var scanned []string
+	for _, profile := range profileNames(config) {
+		path := profileFileFor(profile, "snapshot", ".json")
+		snapshot, err := loadSnapshot(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("Profile has not been scanned, skipping it", "profile", profile, "path", path)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("could not load the snapshot for profile %s: %v", profile, err)
+		}
+		if snapshot.Partial {
+			logger.Warn("Snapshot is from an interrupted scan, so only covers part of the mailbox", "profile", profile)
+		}
+		scanned = append(scanned, profile)
+
+		senders := snapshot.Senders
+		if !opts.KeepAliases {
+			senders = mergeAliases(senders)
+		}
+		for _, sender := range senders {
+			sender.Accounts = map[string]int{profile: sender.Count}
+			combined, exists := merged[sender.Email]
+			if !exists {
+				merged[sender.Email] = &sender
+				order = append(order, sender.Email)
+				continue
+			}
+			mergeSender(combined, sender)
+		}
+	}
+	if len(scanned) == 0 {
+		return fmt.Errorf("no profile has been scanned yet, run 'scan' with --profile for each account first")
+	}
+
+	var senderStats []SenderStats
+	for _, email := range order {
+		senderStats = append(senderStats, *merged[email])
+	}
+	sortSenders(senderStats, opts.Sort)
+	if jsonOutput() {
+		emitJSON(SenderStatsRecord{Type: "accounts", Senders: redactStats(senderStats)})
+		return nil
+	}
+	printAccounts(scanned, senderStats)
+	return nil
+}
+
+// Print the combined view of every account's senders, with how many of
+// each sender's emails are in each account
+func printAccounts(profiles []string, senderStats []SenderStats) {
+	totalEmails := 0
+	shared := 0
+	var totalSize int64
+	for _, sender := range senderStats {
+		totalEmails += sender.Count
+		totalSize += sender.Size
+		if len(sender.Accounts) > 1 {
+			shared++
+		}
+	}
+	fmt.Fprintf(display, "\n%d emails (%s) from %d senders across %d accounts: %s\n", totalEmails, formatSize(totalSize), len(senderStats), len(profiles), strings.Join(profiles, ", "))
+	fmt.Fprintf(display, "%d senders appear in more than one account\n", shared)
+
+	table := newTable("Sender", "Emails", "Size", "Accounts").AlignRight(1, 2)
+	for _, sender := range senderStats[:min(len(senderStats), accountSenders)] {
+		table.AddRow(redactAddress(sender.Email), strconv.Itoa(sender.Count), formatSize(sender.Size), describeAccounts(sender))
+	}
+	table.Render(display)
+	if len(senderStats) > accountSenders {
+		fmt.Fprintf(display, "... and %d more senders\n", len(senderStats)-accountSenders)
+	}
+}
+
+// Describe which accounts a sender's emails are in, most emails first, e.g. "work 120, personal 4"
+func describeAccounts(sender SenderStats) string {
+	var profiles []string
+	for profile := range sender.Accounts {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		a, b := sender.Accounts[profiles[i]], sender.Accounts[profiles[j]]
+		if a != b {
+			return a > b
+		}
+		return profiles[i] < profiles[j]
+	})
+	var parts []string
+	for _, profile := range profiles {
+		parts = append(parts, fmt.Sprintf("%s %d", profile, sender.Accounts[profile]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Domains where unrelated people share a domain, so a shared display name
+// does not mean two addresses belong to the same sender
+var webmailDomains = []string{"gmail.com", "outlook.com", "hotmail.com", "live.com", "yahoo.com", "icloud.com", "aol.com", "proton.me", "protonmail.com"}
+
+// Returns the address in the form shared by all of its aliases: lower cased,
+// and for Gmail addresses without a +tag or dots in the local part and with
+// googlemail.com treated as gmail.com, as Gmail delivers all of these
+// variants to the same mailbox. Other domains may treat a +tag or dots as
+// part of a different mailbox, so their addresses are only lower cased
+func canonicalAddress(email string) string {
+	local, domain := splitAddress(email)
+	if domain == "" {
+		return local
+	}
+	if domain == "googlemail.com" {
+		domain = "gmail.com"
+	}
+	if domain == "gmail.com" {
+		if plus := strings.Index(local, "+"); plus > 0 {
+			local = local[:plus]
+		}
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + domain
+}
+
+// Returns the keys which identify a sender as the same as another: its
+// canonical address, and its display name at its domain, except at webmail
+// providers
+func aliasKeys(sender SenderStats) []string {
+	address := canonicalAddress(sender.Email)
+	keys := []string{"address:" + address}
+	_, domain := splitAddress(address)
+	if name := strings.ToLower(strings.TrimSpace(sender.DisplayName)); name != "" && domain != "" && !slices.Contains(webmailDomains, domain) {
+		keys = append(keys, "name:"+name+"@"+domain)
+	}
+	return keys
+}
+
+// Merge senders which are obviously one sender using several addresses, such
+// as news@foo.com and newsletter@foo.com sending with the same display name,
+// or plus-addressed and dotted variants of one Gmail address such as
+// j.smith+shop@gmail.com and jsmith@googlemail.com. The merged sender is
+// named after the address with the most emails, and records how many emails
+// came from each address. Protected addresses are never merged, so their
+// emails cannot be deleted along with another address's
+func mergeAliases(senderStats []SenderStats) []SenderStats {
+	groupOf := make(map[string]int)
+	var groups [][]SenderStats
+	for _, sender := range senderStats {
+		if isProtected(sender.Email) {
+			groups = append(groups, []SenderStats{sender})
+			continue
+		}
+		keys := aliasKeys(sender)
+		index := -1
+		for _, key := range keys {
+			if i, exists := groupOf[key]; exists {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index = len(groups)
+			groups = append(groups, nil)
+		}
+		for _, key := range keys {
+			groupOf[key] = index
+		}
+		groups[index] = append(groups[index], sender)
+	}
+
+	merged := make([]SenderStats, 0, len(groups))
+	for _, members := range groups {
+		if len(members) == 1 {
+			merged = append(merged, members[0])
+			continue
+		}
+
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].Count > members[j].Count
+		})
+		group := SenderStats{
+			Email:        members[0].Email,
+			DisplayName:  members[0].DisplayName,
+			MonthlyBytes: make(map[string]int64),
+			MonthlyCount: make(map[string]int),
+			Aliases:      make(map[string]int),
+		}
+		for _, sender := range members {
+			mergeSender(&group, sender)
+			group.Aliases[sender.Email] += sender.Count
+		}
+		logger.Debug("Merged sender aliases", "sender", group.Email, "addresses", len(members))
+		merged = append(merged, group)
+	}
+	return merged
+}
+
+// Describe the addresses a merged sender used, with how many emails came from
+// each, e.g. "news@foo.com 40, newsletter@foo.com 12"
+func describeAliases(sender SenderStats) string {
+	addresses := make([]string, 0, len(sender.Aliases))
+	for address := range sender.Aliases {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		a, b := sender.Aliases[addresses[i]], sender.Aliases[addresses[j]]
+		if a != b {
+			return a > b
+		}
+		return addresses[i] < addresses[j]
+	})
+
+	parts := make([]string, len(addresses))
+	for i, address := range addresses {
+		parts[i] = fmt.Sprintf("%s %d", redactAddress(address), sender.Aliases[address])
+	}
+	return strings.Join(parts, ", ")
+}
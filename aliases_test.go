@@ -0,0 +1,102 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestCanonicalAddress(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"News@Store.com", "news@store.com"},
+		{"j.smith+shop@gmail.com", "jsmith@gmail.com"},
+		{"J.Smith@GoogleMail.com", "jsmith@gmail.com"},
+		{"+tag@gmail.com", "+tag@gmail.com"},
+		{"news+tag@store.com", "news+tag@store.com"},
+		{"first.last@store.com", "first.last@store.com"},
+		{"nodomain", "nodomain"},
+	}
+	for _, tt := range tests {
+		if got := canonicalAddress(tt.email); got != tt.want {
+			t.Errorf("canonicalAddress(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
+
+// Senders with plus-addressed and dotted Gmail variants, and a store sending
+// from two addresses under one display name, as do two unrelated webmail users
+func aliasSenders() []SenderStats {
+	return []SenderStats{
+		{Email: "j.smith+shop@gmail.com", Count: 2, Ids: []string{"a1", "a2"}},
+		{Email: "news@store.com", DisplayName: "Store", Count: 1, Ids: []string{"n1"}},
+		{Email: "jsmith@googlemail.com", Count: 3, Ids: []string{"b1", "b2", "b3"}},
+		{Email: "newsletter@store.com", DisplayName: "Store", Count: 5, Ids: []string{"l1", "l2", "l3", "l4", "l5"}},
+		{Email: "alice@gmail.com", DisplayName: "Store", Count: 1, Ids: []string{"x1"}},
+		{Email: "bob@gmail.com", DisplayName: "Store", Count: 1, Ids: []string{"y1"}},
+	}
+}
+
+func TestMergeAliases(t *testing.T) {
+	type merged struct {
+		email   string
+		ids     []string
+		aliases map[string]int
+	}
+	tests := []struct {
+		name      string
+		protected []string
+		want      []merged
+	}{
+		{
+			name: "no protected senders",
+			want: []merged{
+				{"jsmith@googlemail.com", []string{"b1", "b2", "b3", "a1", "a2"}, map[string]int{"jsmith@googlemail.com": 3, "j.smith+shop@gmail.com": 2}},
+				{"newsletter@store.com", []string{"l1", "l2", "l3", "l4", "l5", "n1"}, map[string]int{"newsletter@store.com": 5, "news@store.com": 1}},
+				{"alice@gmail.com", []string{"x1"}, nil},
+				{"bob@gmail.com", []string{"y1"}, nil},
+			},
+		},
+		{
+			name:      "protected address is not merged",
+			protected: []string{"news@store.com"},
+			want: []merged{
+				{"jsmith@googlemail.com", []string{"b1", "b2", "b3", "a1", "a2"}, map[string]int{"jsmith@googlemail.com": 3, "j.smith+shop@gmail.com": 2}},
+				{"news@store.com", []string{"n1"}, nil},
+				{"newsletter@store.com", []string{"l1", "l2", "l3", "l4", "l5"}, nil},
+				{"alice@gmail.com", []string{"x1"}, nil},
+				{"bob@gmail.com", []string{"y1"}, nil},
+			},
+		},
+		{
+			name:      "protected Gmail variant is not merged",
+			protected: []string{"J.Smith+Shop@gmail.com"},
+			want: []merged{
+				{"j.smith+shop@gmail.com", []string{"a1", "a2"}, nil},
+				{"newsletter@store.com", []string{"l1", "l2", "l3", "l4", "l5", "n1"}, map[string]int{"newsletter@store.com": 5, "news@store.com": 1}},
+				{"jsmith@googlemail.com", []string{"b1", "b2", "b3"}, nil},
+				{"alice@gmail.com", []string{"x1"}, nil},
+				{"bob@gmail.com", []string{"y1"}, nil},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, Options{Protected: tt.protected})
+			got := mergeAliases(aliasSenders())
+			if len(got) != len(tt.want) {
+				t.Fatalf("mergeAliases() returned %d senders, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].Email != want.email || !slices.Equal(got[i].Ids, want.ids) || !maps.Equal(got[i].Aliases, want.aliases) {
+					t.Errorf("sender %d = %s %v %v, want %s %v %v", i, got[i].Email, got[i].Ids, got[i].Aliases, want.email, want.ids, want.aliases)
+				}
+				if got[i].Count != len(want.ids) {
+					t.Errorf("%s count = %d, want %d", got[i].Email, got[i].Count, len(want.ids))
+				}
+			}
+		})
+	}
+}
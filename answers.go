@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Answers which may be given for a sender in an answers file
+var scriptedAnswers = []string{"yes", "no", "keep", "archive", "read", "domain", "protect", "quit"}
+
+// Prompts other than the sender prompt which may be answered in an answers
+// file, with a line such as "prompt:confirm=yes"
+var scriptedPrompts = []string{"confirm", "permanent", "select", "similar", "page"}
+
+// Answers loaded from --answers, keyed by lower case sender. The "*"
+// entry, if present, answers for every sender which is not listed, and
+// "prompt:<name>" entries answer the other prompts
+var answers map[string]string
+
+// Load the answers file given with --answers, or read the answers from
+// stdin if it is "-". Each line is a sender=answer pair, e.g.
+// "news@store.com=yes", and blank lines and lines starting with # are ignored
+func loadAnswers(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	var r io.Reader = stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	answers = make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sender, answer, ok := strings.Cut(line, "=")
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if name, isPrompt := strings.CutPrefix(strings.TrimSpace(sender), "prompt:"); isPrompt && ok {
+			if !slices.Contains(scriptedPrompts, name) {
+				return fmt.Errorf("line %d of answers: unknown prompt %q, expected one of %s", n, name, strings.Join(scriptedPrompts, ", "))
+			}
+			answers["prompt:"+name] = answer
+			continue
+		}
+		if !ok || !validAnswer(answer) {
+			return fmt.Errorf("line %d of answers: expected sender=%s", n, strings.Join(scriptedAnswers, "|"))
+		}
+		answers[strings.ToLower(strings.TrimSpace(sender))] = answer
+	}
+	return scanner.Err()
+}
+
+// Returns true if the answer may be given in an answers file
+func validAnswer(answer string) bool {
+	for _, a := range scriptedAnswers {
+		if a == answer {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the answer given for the sender in the answers file, if there is
+// one. A merged sender may be answered under any of its addresses, and if
+// its addresses are answered differently it is skipped, as it is not clear
+// which answer was meant
+func scriptedAnswer(sender SenderStats) (string, bool) {
+	addresses := []string{sender.Email}
+	for address := range sender.Aliases {
+		addresses = append(addresses, address)
+	}
+	found := ""
+	for _, address := range addresses {
+		answer, ok := answers[strings.ToLower(address)]
+		if !ok {
+			continue
+		}
+		if found != "" && answer != found {
+			logger.Warn("Addresses of a merged sender have different answers, skipping it", "sender", sender.Email)
+			return "no", true
+		}
+		found = answer
+	}
+	if found != "" {
+		return found, true
+	}
+	answer, ok := answers["*"]
+	return answer, ok
+}
+
+// Read the answer to a prompt other than the sender prompt, such as a
+// confirmation. When answers are scripted, stdin is not read, as nobody may be
+// there to answer or the answers themselves were read from it, so the answer
+// comes from the prompt's line in the answers file, or the prompt is skipped
+func readPrompt(name string) (string, bool) {
+	if answers == nil {
+		return readLine()
+	}
+	answer, ok := answers["prompt:"+name]
+	if !ok {
+		fmt.Fprintf(display, "No prompt:%s answer in the answers file, skipping\n", name)
+		return "", true
+	}
+	fmt.Fprintf(display, "Answering %q from the answers file\n", answer)
+	return answer, true
+}
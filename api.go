@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Number of times a single API call is attempted before giving up
+const maxAttempts = 5
+
+// Delay before the first retry of a failed call, doubled for each retry after that
+const initialBackoff = time.Second
+
+// Gmail's per-user rate limit, in quota units per second
+const userUnitsPerSecond = 250
+
+// Gmail's daily quota for the whole Cloud project, in quota units
+const dailyUnits = 1_000_000_000
+
+// Share of a limit which can be used before a warning is shown
+const quotaWarnFraction = 0.8
+
+// Quota units charged for each API method. Methods not listed cost 5.
+// Drive's about.get is charged against Drive's quota, not Gmail's
+var quotaCosts = map[string]int{
+	"about.get":            0,
+	"users.getProfile":     1,
+	"labels.list":          1,
+	"labels.get":           1,
+	"labels.create":        5,
+	"history.list":         2,
+	"messages.list":        5,
+	"messages.get":         5,
+	"messages.trash":       5,
+	"messages.untrash":     5,
+	"messages.delete":      10,
+	"messages.batchModify": 50,
+	"messages.batchDelete": 50,
+	"threads.trash":        10,
+}
+
+// Counts of the calls made to one API method
+type APIMetrics struct {
+	Calls    int
+	Retries  int
+	Failures int
+	Units    int
+	Time     time.Duration
+}
+
+var (
+	// Calls made through callAPI, by method name
+	apiMetrics = make(map[string]*APIMetrics)
+
+	// Retries used so far this run, out of --retry-budget
+	retriesUsed int
+
+	// When the last write call was made, used to space writes out by --rate-limit
+	lastWrite time.Time
+
+	// Quota units used so far this run, shown on progress lines
+	quotaUnits int
+
+	// Quota units used in the current second, to stay under the per-user rate limit
+	secondUnits int
+	secondStart time.Time
+	rateWarned  bool
+)
+
+// An API call which can be made with a context, such as
+// *gmail.UsersMessagesGetCall, returning a result of type T
+type apiCall[C any, T any] interface {
+	Context(context.Context) C
+	Do(...googleapi.CallOption) (T, error)
+}
+
+// Make a read-only API call, retrying it if it fails with a temporary error.
+// name identifies the method in the metrics and logs. The call is made with
+// ctx, so it is abandoned as soon as the run is interrupted
+func callAPI[C apiCall[C, T], T any](ctx context.Context, name string, c C) (T, error) {
+	return call(ctx, name, c.Context(ctx).Do)
+}
+
+// Make an API call which changes the mailbox. As well as being retried,
+// writes are spaced out by --rate-limit to stay under Gmail's quotas. No
+// write is started once ctx is cancelled, but one already sent is left to
+// finish, so the journal always knows whether it happened
+func callWriteAPI[C apiCall[C, T], T any](ctx context.Context, name string, c C) (T, error) {
+	if err := sleepContext(ctx, opts.RateLimit-time.Since(lastWrite)); err != nil {
+		var zero T
+		return zero, err
+	}
+	defer func() {
+		lastWrite = time.Now()
+	}()
+	return call(ctx, name, c.Context(context.WithoutCancel(ctx)).Do)
+}
+
+// Make an API call, retrying temporary failures with exponential backoff
+// until the call's attempts or the run's retry budget run out. Waiting
+// stops as soon as ctx is cancelled
+func call[T any](ctx context.Context, name string, do func(...googleapi.CallOption) (T, error)) (T, error) {
+	metrics, ok := apiMetrics[name]
+	if !ok {
+		metrics = &APIMetrics{}
+		apiMetrics[name] = metrics
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.Time += time.Since(start)
+	}()
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		if err := chargeQuota(ctx, name, metrics); err != nil {
+			var zero T
+			return zero, err
+		}
+		metrics.Calls++
+		result, err := do()
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return result, errInterrupted
+		}
+		if attempt == maxAttempts || !retryable(err) || retriesUsed >= opts.RetryBudget {
+			metrics.Failures++
+			return result, err
+		}
+
+		retriesUsed++
+		metrics.Retries++
+		logger.Warn("API call failed, retrying", "method", name, "attempt", attempt, "backoff", backoff, "err", err)
+		if sleepContext(ctx, backoff) != nil {
+			return result, errInterrupted
+		}
+		backoff *= 2
+	}
+}
+
+// Wait for the given time, returning errInterrupted straight away if ctx is
+// cancelled first, or has already been
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errInterrupted
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Add the cost of a call to the quota used. If the call would take the run
+// over Gmail's per-user rate limit, it first waits for the next second, so
+// reads and writes alike stay under the limit. Every attempt is charged, as
+// Gmail charges for failed calls too, and a warning is shown once if the run
+// is close to the project's daily quota
+func chargeQuota(ctx context.Context, name string, metrics *APIMetrics) error {
+	cost, ok := quotaCosts[name]
+	if !ok {
+		cost = 5
+	}
+
+	if elapsed := time.Since(secondStart); elapsed < time.Second && secondUnits+cost > userUnitsPerSecond {
+		if !rateWarned {
+			rateWarned = true
+			logger.Debug("Waiting to stay under Gmail's per-user rate limit", "units_per_second", secondUnits, "limit", userUnitsPerSecond)
+		}
+		if err := sleepContext(ctx, time.Second-elapsed); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	if now.Sub(secondStart) >= time.Second {
+		secondStart = now
+		secondUnits = 0
+	}
+	secondUnits += cost
+
+	metrics.Units += cost
+	before := quotaUnits
+	quotaUnits += cost
+	if before < dailyUnits*quotaWarnFraction && quotaUnits >= dailyUnits*quotaWarnFraction {
+		logger.Warn("Nearly all of the project's daily Gmail API quota has been used", "units", quotaUnits, "limit", dailyUnits)
+	}
+	return nil
+}
+
+// Returns true if the error is one which may succeed if the call is made
+// again, i.e. rate limiting or a server side failure
+func retryable(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return quotaExceeded(err)
+}
+
+// An API call which only returns an error, such as *gmail.UsersMessagesDeleteCall
+type errorCall[C any] interface {
+	Context(context.Context) C
+	Do(...googleapi.CallOption) error
+}
+
+// Adapts an API call which only returns an error for use with callAPI
+type noResultCall[C errorCall[C]] struct {
+	call C
+}
+
+// Adapts an API call which only returns an error, such as messages.delete, for use with callAPI
+func noResult[C errorCall[C]](c C) noResultCall[C] {
+	return noResultCall[C]{call: c}
+}
+
+// Set the context the call is made with
+func (n noResultCall[C]) Context(ctx context.Context) noResultCall[C] {
+	return noResultCall[C]{call: n.call.Context(ctx)}
+}
+
+// Make the call, returning an empty result
+func (n noResultCall[C]) Do(callOptions ...googleapi.CallOption) (struct{}, error) {
+	return struct{}{}, n.call.Do(callOptions...)
+}
+
+// Log how many calls were made to each API method, and how many of them were retried or failed
+func logAPIMetrics() {
+	var names []string
+	for name := range apiMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := apiMetrics[name]
+		logger.Debug("API usage", "method", name, "calls", m.Calls, "retries", m.Retries, "failures", m.Failures, "units", m.Units, "time", m.Time.Round(time.Millisecond))
+	}
+	if quotaUnits > 0 {
+		logger.Info("Gmail API quota used", "units", quotaUnits)
+	}
+	if retriesUsed > 0 {
+		logger.Info("API calls were retried", "retries", retriesUsed, "budget", opts.RetryBudget)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Number of senders shown in the attachment report
+const attachmentSenders = 20
+
+// Count the emails with attachments from each sender, and their total size,
+// using the sizes recorded during the scan
+func countAttachments(ctx context.Context, srv *gmail.Service, senderMap map[string]*SenderStats, sizes map[string]int64, query string) error {
+	ids, err := listMessageIds(ctx, srv, strings.TrimSpace(query+" has:attachment"))
+	if err != nil {
+		return err
+	}
+	withAttachments := make(map[string]bool)
+	for _, id := range ids {
+		withAttachments[id] = true
+	}
+
+	for _, stats := range senderMap {
+		for _, id := range stats.Ids {
+			if withAttachments[id] {
+				stats.Attachments++
+				stats.AttachmentSize += sizes[id]
+			}
+		}
+	}
+	return nil
+}
+
+// Print the senders whose attachments take up the most storage, for the attachments command
+func printAttachmentReport(senderStats []SenderStats) {
+	var senders []SenderStats
+	var total int64
+	for _, sender := range senderStats {
+		if sender.Attachments > 0 {
+			senders = append(senders, sender)
+			total += sender.AttachmentSize
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return senders[i].AttachmentSize > senders[j].AttachmentSize
+	})
+
+	fmt.Fprintf(display, "\nEmails with attachments take up %s\n", formatSize(total))
+	if len(senders) == 0 {
+		return
+	}
+	fmt.Fprintf(display, "Senders whose attachments take up the most storage:\n")
+	table := newTable("#", "Sender", "With attachments", "Attachment size", "Share of sender's storage").AlignRight(0, 2, 3, 4)
+	for i, sender := range senders[:min(len(senders), attachmentSenders)] {
+		share := 0.0
+		if sender.Size > 0 {
+			share = float64(sender.AttachmentSize) / float64(sender.Size)
+		}
+		table.AddRow(strconv.Itoa(i+1), redactAddress(sender.Email), strconv.Itoa(sender.Attachments), formatSize(sender.AttachmentSize), formatPercent(share))
+	}
+	table.Render(display)
+}
+
+// Number of senders whose attachment types are listed in the attachment report
+const attachmentTypeSenders = 10
+
+// Total count and size of the attachments of one type
+type attachmentType struct {
+	mimeType   string
+	extensions map[string]bool
+	count      int
+	size       int64
+}
+
+// Returns every part of a message which is an attachment, i.e. has a filename,
+// including parts nested inside other parts
+func attachmentParts(part *gmail.MessagePart) []*gmail.MessagePart {
+	if part == nil {
+		return nil
+	}
+	var parts []*gmail.MessagePart
+	if part.Filename != "" {
+		parts = append(parts, part)
+	}
+	for _, child := range part.Parts {
+		parts = append(parts, attachmentParts(child)...)
+	}
+	return parts
+}
+
+// Print the types of attachment taking up the most storage across the
+// mailbox, and for the senders with the most attachment storage. Every
+// email with attachments has its parts fetched, without their contents
+func printAttachmentTypes(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) error {
+	senderOf := make(map[string]string)
+	for _, sender := range senderStats {
+		if sender.Attachments == 0 {
+			continue
+		}
+		for _, id := range sender.Ids {
+			senderOf[id] = sender.Email
+		}
+	}
+	query, _ := scanQuery()
+	ids, err := listMessageIds(ctx, srv, strings.TrimSpace(query+" has:attachment"))
+	if err != nil {
+		return err
+	}
+
+	types := make(map[string]*attachmentType)
+	senderTypes := make(map[string]map[string]int64)
+	defer startWork()()
+	progress := newProgress("Fetching attachment types", int64(len(ids)))
+	for _, id := range ids {
+		if interrupted() {
+			break
+		}
+		sender, scanned := senderOf[id]
+		progress.Add(1)
+		if !scanned {
+			continue
+		}
+		message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("full").
+			Fields("payload(filename,mimeType,body/size,parts(filename,mimeType,body/size,parts(filename,mimeType,body/size,parts(filename,mimeType,body/size))))"))
+		if err != nil {
+			logger.Warn("Could not get email attachments, continuing", "id", id, "err", err)
+			continue
+		}
+
+		for _, part := range attachmentParts(message.Payload) {
+			mimeType := strings.ToLower(part.MimeType)
+			if mimeType == "" {
+				mimeType = "application/octet-stream"
+			}
+			var size int64
+			if part.Body != nil {
+				size = part.Body.Size
+			}
+
+			stats, exists := types[mimeType]
+			if !exists {
+				stats = &attachmentType{mimeType: mimeType, extensions: make(map[string]bool)}
+				types[mimeType] = stats
+			}
+			stats.count++
+			stats.size += size
+			if ext := strings.ToLower(filepath.Ext(part.Filename)); ext != "" {
+				stats.extensions[ext] = true
+			}
+
+			if senderTypes[sender] == nil {
+				senderTypes[sender] = make(map[string]int64)
+			}
+			senderTypes[sender][mimeType] += size
+		}
+	}
+	progress.Finish()
+
+	var sorted []*attachmentType
+	for _, stats := range types {
+		sorted = append(sorted, stats)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].size > sorted[j].size
+	})
+
+	fmt.Fprintf(display, "\nAttachment storage by type:\n")
+	table := newTable("Type", "Extensions", "Attachments", "Size").AlignRight(2, 3)
+	for _, stats := range sorted {
+		var extensions []string
+		for ext := range stats.extensions {
+			extensions = append(extensions, ext)
+		}
+		sort.Strings(extensions)
+		table.AddRow(stats.mimeType, strings.Join(extensions, " "), strconv.Itoa(stats.count), formatSize(stats.size))
+	}
+	table.Render(display)
+
+	// The senders with the most attachment storage, with their main types of attachment
+	var senders []SenderStats
+	for _, sender := range senderStats {
+		if senderTypes[sender.Email] != nil {
+			senders = append(senders, sender)
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return senders[i].AttachmentSize > senders[j].AttachmentSize
+	})
+	if len(senders) == 0 {
+		return nil
+	}
+	fmt.Fprintf(display, "Attachment types of the senders with the most attachment storage:\n")
+	senderTable := newTable("Sender", "Attachment size", "Types")
+	for _, sender := range senders[:min(len(senders), attachmentTypeSenders)] {
+		byType := senderTypes[sender.Email]
+		mimeTypes := make([]string, 0, len(byType))
+		for mimeType := range byType {
+			mimeTypes = append(mimeTypes, mimeType)
+		}
+		sort.Slice(mimeTypes, func(i, j int) bool {
+			return byType[mimeTypes[i]] > byType[mimeTypes[j]]
+		})
+		var parts []string
+		for _, mimeType := range mimeTypes[:min(len(mimeTypes), 3)] {
+			parts = append(parts, fmt.Sprintf("%s %s", mimeType, formatSize(byType[mimeType])))
+		}
+		senderTable.AddRow(redactAddress(sender.Email), formatSize(sender.AttachmentSize), strings.Join(parts, ", "))
+	}
+	senderTable.Render(display)
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Local parts of addresses which are only used to send automated email
+var automatedLocalPart = regexp.MustCompile(`^(no-?reply|do-?not-?reply|donotreply|mailer-daemon|postmaster|bounces?|notifications?|notify|alerts?|automated|auto|robot|bot|system|daemon)([.+_-].*)?$`)
+
+// Returns true if the email looks like it was sent automatically rather than
+// by a person: the sender is a no-reply or notification address, the
+// Auto-Submitted header marks it as automatic, or it is an auto reply
+func isAutomated(email string, headers map[string]string) bool {
+	local, _ := splitAddress(email)
+	if automatedLocalPart.MatchString(local) {
+		return true
+	}
+	if submitted := strings.ToLower(strings.TrimSpace(headers["auto-submitted"])); submitted != "" && submitted != "no" {
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(headers["precedence"]), "auto_reply")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Local parts of the addresses mail servers send delivery failures from
+var bounceLocalPart = regexp.MustCompile(`^(mailer-daemon|postmaster)$`)
+
+// Subject lines of delivery failure and delay notifications
+var bounceSubject = regexp.MustCompile(`delivery status notification|undeliver(able|ed)|mail delivery (failed|failure|subsystem)|delivery (failure|has failed)|returned mail|failure notice|could not be delivered`)
+
+// Gmail search matching the emails which might be bounces. Each one is then
+// checked with isBounce, as the search cannot look at the Content-Type
+const bounceQuery = `{from:mailer-daemon from:postmaster subject:"delivery status notification" subject:undeliverable subject:"mail delivery failed" subject:"delivery failure" subject:"returned mail" subject:"failure notice"}`
+
+// Returns true if the email is a bounce: a delivery status notification
+// saying an email could not be delivered, or was delayed
+func isBounce(email string, headers map[string]string) bool {
+	local, _ := splitAddress(email)
+	if bounceLocalPart.MatchString(local) {
+		return true
+	}
+	contentType := strings.ToLower(headers["content-type"])
+	if strings.Contains(contentType, "multipart/report") && strings.Contains(contentType, "delivery-status") {
+		return true
+	}
+	return bounceSubject.MatchString(strings.ToLower(headers["subject"]))
+}
+
+// Handles the 'bounces' command, which shows how many bounces and delivery
+// failure notices are in the mailbox, and 'bounces delete <age>', which moves
+// those older than the given age to the Trash
+func runBouncesCommand(ctx context.Context, srv *gmail.Service, args []string) error {
+	if len(args) == 0 {
+		return showBounces(ctx, srv)
+	}
+	if len(args) == 2 && args[0] == "delete" {
+		age, err := parseAge(args[1])
+		if err != nil {
+			return err
+		}
+		return deleteBounces(ctx, srv, age, time.Now())
+	}
+	return fmt.Errorf("usage: bounces [delete <age>]")
+}
+
+// Find the bounces matching the query, returning their IDs, total size and
+// when the oldest one arrived
+func listBounces(ctx context.Context, srv *gmail.Service, query string) ([]string, int64, time.Time, error) {
+	scanTerms, _ := scanQuery()
+	candidates, err := listMessageIds(ctx, srv, strings.TrimSpace(strings.Join([]string{scanTerms, bounceQuery, query}, " ")))
+	if err != nil {
+		return nil, 0, time.Time{}, err
+	}
+
+	defer startWork()()
+	var ids []string
+	var size int64
+	var oldest time.Time
+	progress := newProgress("Checking bounces", int64(len(candidates)))
+	for _, id := range candidates {
+		if interrupted() {
+			break
+		}
+		message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders("From", "Subject", "Content-Type", "Date"))
+		progress.Add(1)
+		if err != nil {
+			logger.Warn("Could not get email metadata, continuing", "id", id, "err", err)
+			continue
+		}
+		headers := messageHeaders(message)
+		if !isBounce(extractEmail(headers["from"]), headers) {
+			continue
+		}
+		ids = append(ids, id)
+		size += message.SizeEstimate
+		if received := messageTime(message); !received.IsZero() && (oldest.IsZero() || received.Before(oldest)) {
+			oldest = received
+		}
+	}
+	progress.Finish()
+	return ids, size, oldest, nil
+}
+
+// Print how many bounces are in the mailbox, how much storage they take up and how old the oldest is
+func showBounces(ctx context.Context, srv *gmail.Service) error {
+	ids, size, oldest, err := listBounces(ctx, srv, "")
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "No bounces found\n")
+		return nil
+	}
+	fmt.Fprintf(display, "\nFound %d bounces and delivery failure notices (%s), the oldest from %s\n", len(ids), formatSize(size), formatDate(oldest))
+	fmt.Fprintf(display, "Run 'bounces delete <age>' to move those older than the age to the Trash\n")
+	return nil
+}
+
+// Move the bounces older than the given age to the Trash, after confirming
+func deleteBounces(ctx context.Context, srv *gmail.Service, age time.Duration, now time.Time) error {
+	cutoff := now.Add(-age)
+	ids, size, _, err := listBounces(ctx, srv, "before:"+cutoff.Format("2006/01/02"))
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "No bounces from before %s\n", formatDate(cutoff))
+		return nil
+	}
+
+	fmt.Fprintf(display, "Would you like to delete %d bounces (%s) from before %s? (yes/no):\n", len(ids), formatSize(size), formatDate(cutoff))
+	response, _ := readPrompt("confirm")
+	if strings.ToLower(response) != "yes" {
+		return nil
+	}
+	fmt.Fprintf(display, "Deleting %d bounces...\n", len(ids))
+	if _, err := deleteEmails(ctx, srv, "bounces", ids); err != nil {
+		logger.Error("Error deleting bounces", "err", err)
+	}
+	return nil
+}
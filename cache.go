@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Headers kept for each cached email, which are the ones the scan uses
+var cachedHeaders = []string{"from", "date", "subject", "message-id", "list-unsubscribe", "list-id", "x-forwarded-for", "delivered-to", "content-type", "content-class", "auto-submitted", "precedence", "to", "cc"}
+
+// Metadata of every email fetched by earlier scans, saved to disk so later
+// scans only need to fetch emails they have not seen before. It is kept as one
+// JSON file rather than in an embedded database such as bbolt or SQLite, so the
+// tool needs no extra dependencies or cgo, at the cost of reading and writing
+// the whole file each run. Only the headers the scan uses are kept, so even a
+// mailbox of a few hundred thousand emails makes a file of tens of megabytes
+type MessageCache struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	Profile   string    `json:"profile"`
+
+	// The mailbox history ID and search query of the last complete scan. Later
+	// scans with the same query only apply the changes since then
+	HistoryID uint64 `json:"history_id,omitempty"`
+	Query     string `json:"query,omitempty"`
+
+	Messages map[string]*gmail.Message `json:"messages"`
+}
+
+// Returns the path the message cache is kept at for the selected profile
+func cachePath() string {
+	return profileFile("cache", ".json")
+}
+
+// Load the message cache, unless --no-cache was given. A missing or
+// unreadable cache is treated as empty, so the scan just fetches everything again
+func loadCache() *MessageCache {
+	if opts.NoCache {
+		return &MessageCache{Profile: opts.Profile, Messages: make(map[string]*gmail.Message)}
+	}
+	return readCache()
+}
+
+// Read the message cache from disk, treating a missing or unreadable cache as empty
+func readCache() *MessageCache {
+	cache := &MessageCache{Profile: opts.Profile, Messages: make(map[string]*gmail.Message)}
+
+	data, err := os.ReadFile(cachePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache
+	} else if err != nil {
+		logger.Warn("Unable to read message cache, fetching every email", "path", cachePath(), "err", err)
+		return cache
+	}
+	if err := json.Unmarshal(data, cache); err != nil || cache.Messages == nil {
+		logger.Warn("Unable to parse message cache, fetching every email", "path", cachePath(), "err", err)
+		return &MessageCache{Profile: opts.Profile, Messages: make(map[string]*gmail.Message)}
+	}
+	logger.Debug("Loaded message cache", "path", cachePath(), "emails", len(cache.Messages))
+	return cache
+}
+
+// Save the message cache to disk. It is written to a temporary file which then
+// replaces the cache, so a run stopped part way through never leaves it truncated
+func saveCache(cache *MessageCache) error {
+	cache.UpdatedAt = time.Now()
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	temp := cachePath() + ".tmp"
+	if err := os.WriteFile(temp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, cachePath())
+}
+
+// Add an email to the cache, keeping only what the scan uses so the cache stays small
+func cacheMessage(cache *MessageCache, message *gmail.Message) {
+	kept := &gmail.Message{
+		Id:           message.Id,
+		ThreadId:     message.ThreadId,
+		LabelIds:     message.LabelIds,
+		SizeEstimate: message.SizeEstimate,
+		InternalDate: message.InternalDate,
+		Payload:      &gmail.MessagePart{},
+	}
+	if message.Payload != nil {
+		for _, header := range message.Payload.Headers {
+			if slices.Contains(cachedHeaders, strings.ToLower(header.Name)) {
+				kept.Payload.Headers = append(kept.Payload.Headers, header)
+			}
+		}
+	}
+	cache.Messages[message.Id] = kept
+}
+
+// Remove emails which were not seen by a complete scan, as they have since been deleted
+func pruneCache(cache *MessageCache, seen map[string]bool) {
+	for id := range cache.Messages {
+		if !seen[id] {
+			delete(cache.Messages, id)
+		}
+	}
+}
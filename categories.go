@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// The category tabs Gmail sorts email into, besides Primary
+var gmailCategories = []struct {
+	name  string
+	label string
+}{
+	{"Promotions", "CATEGORY_PROMOTIONS"},
+	{"Social", "CATEGORY_SOCIAL"},
+	{"Updates", "CATEGORY_UPDATES"},
+	{"Forums", "CATEGORY_FORUMS"},
+}
+
+// Handles the 'categories' command, which breaks the mailbox down by
+// category tab with the number of emails and storage in each, and offers
+// to delete every email in a whole category
+func runCategoriesCommand(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) error {
+	var totalEmails int
+	var totalSize int64
+	for _, sender := range senderStats {
+		totalEmails += sender.Count
+		totalSize += sender.Size
+	}
+
+	fmt.Fprintf(display, "\nEmails in each category:\n")
+	table := newTable("#", "Category", "Emails", "Size", "Share of storage", "Senders").AlignRight(0, 2, 3, 4, 5)
+	for i, category := range gmailCategories {
+		count, senders := 0, 0
+		var size int64
+		for _, sender := range senderStats {
+			if sender.Labels[category.label] > 0 {
+				count += sender.Labels[category.label]
+				size += sender.CategorySizes[category.label]
+				senders++
+			}
+		}
+		share := 0.0
+		if totalSize > 0 {
+			share = float64(size) / float64(totalSize)
+		}
+		table.AddRow(strconv.Itoa(i+1), category.name, strconv.Itoa(count), formatSize(size), formatPercent(share), strconv.Itoa(senders))
+	}
+	table.Render(display)
+	fmt.Fprintf(display, "Scanned %d emails (%s) in total\n", totalEmails, formatSize(totalSize))
+
+	for {
+		fmt.Fprintf(display, "Which categories would you like to delete every email from? (numbers e.g. 1,3/none):\n")
+		response, _ := readPrompt("select")
+		response = strings.ToLower(response)
+		if response == "none" || response == "" {
+			return nil
+		}
+
+		selected, err := parseSelection(response, len(gmailCategories))
+		if err != nil {
+			fmt.Fprintf(display, "Invalid selection: %v\n", err)
+			continue
+		}
+		for _, n := range selected {
+			if err := deleteCategory(ctx, srv, gmailCategories[n-1].name); err != nil {
+				return err
+			}
+			if interrupted() {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+// Move every scanned email in the category to the Trash, after confirming.
+// Protected senders' emails are left alone
+func deleteCategory(ctx context.Context, srv *gmail.Service, name string) error {
+	query, _ := scanQuery()
+	terms := []string{query, "category:" + strings.ToLower(name)}
+	for _, sender := range opts.Protected {
+		terms = append(terms, "-from:"+sender)
+	}
+	ids, err := listMessageIds(ctx, srv, strings.TrimSpace(strings.Join(terms, " ")))
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "No emails found in %s\n", name)
+		return nil
+	}
+
+	fmt.Fprintf(display, "Would you like to delete all %d emails in %s? (yes/no):\n", len(ids), name)
+	response, _ := readPrompt("confirm")
+	if strings.ToLower(response) != "yes" {
+		return nil
+	}
+	fmt.Fprintf(display, "Deleting %d emails in %s...\n", len(ids), name)
+	if _, err := deleteEmails(ctx, srv, "category:"+strings.ToLower(name), ids); err != nil {
+		logger.Error("Error deleting category", "category", name, "err", err)
+	}
+	return nil
+}
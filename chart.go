@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Number of senders shown in the scan summary's bar chart
+const chartSenders = 10
+
+// A labelled value drawn as one bar of a bar chart
+type chartBar struct {
+	label string
+	value int
+}
+
+// Draw a horizontal bar chart, scaling the bars so the longest fills the
+// width left after the labels and values
+func drawBarChart(w io.Writer, bars []chartBar) {
+	labelWidth, valueWidth, peak := 0, 0, 0
+	for _, bar := range bars {
+		labelWidth = max(labelWidth, utf8.RuneCountInString(bar.label))
+		valueWidth = max(valueWidth, len(strconv.Itoa(bar.value)))
+		peak = max(peak, bar.value)
+	}
+	barWidth := max(tableWidth(w)-labelWidth-valueWidth-4, 10)
+
+	for _, bar := range bars {
+		length := 0
+		if peak > 0 {
+			length = bar.value * barWidth / peak
+		}
+		// Non-zero values always get a sliver of a bar, so they are not mistaken for nothing
+		if length == 0 && bar.value > 0 {
+			length = 1
+		}
+		padding := strings.Repeat(" ", labelWidth-utf8.RuneCountInString(bar.label))
+		fmt.Fprintf(w, "%s%s  %*d", bar.label, padding, valueWidth, bar.value)
+		if length > 0 {
+			fmt.Fprintf(w, " %s", strings.Repeat("█", length))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// Draw bar charts of the senders with the most emails, and of the number of
+// emails received in each of the last year's months, for the scan summary
+func printScanCharts(senderStats []SenderStats, now time.Time) {
+	byCount := slices.Clone(senderStats)
+	sortSenders(byCount, "count")
+	var senders []chartBar
+	for _, sender := range byCount[:min(len(byCount), chartSenders)] {
+		senders = append(senders, chartBar{label: truncate(redactAddress(sender.Email), 40), value: sender.Count})
+	}
+	if len(senders) > 0 {
+		fmt.Fprintf(display, "\nTop senders by emails:\n")
+		drawBarChart(display, senders)
+	}
+
+	monthly := make(map[string]int)
+	for _, sender := range senderStats {
+		for month, count := range sender.MonthlyCount {
+			monthly[month] += count
+		}
+	}
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var months []chartBar
+	for i := sparklineMonths - 1; i >= 0; i-- {
+		month := firstOfMonth.AddDate(0, -i, 0).Format("2006-01")
+		months = append(months, chartBar{label: month, value: monthly[month]})
+	}
+	fmt.Fprintf(display, "\nEmails received each month:\n")
+	drawBarChart(display, months)
+}
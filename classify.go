@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kinds of transactional email, and the subject lines which identify them.
+// Kinds are tried in order, and an email is given the first kind which matches
+var transactionalKinds = []struct {
+	name    string
+	subject *regexp.Regexp
+}{
+	{"calendar invite", regexp.MustCompile(`^(updated invitation|invitation|accepted|declined|tentatively accepted|cancell?ed event)\b.*:`)},
+	{"password reset", regexp.MustCompile(`password reset|reset your password|verification code|verify your|sign-in code|one-time (pass)?code|security code`)},
+	{"shipping", regexp.MustCompile(`shipped|dispatched|out for delivery|has been delivered|tracking number|on its way|shipment`)},
+	{"receipt", regexp.MustCompile(`receipt|invoice|order confirm|your order|payment (received|confirm)|purchase confirm`)},
+}
+
+// Returns the kind of transactional email the email is, such as a receipt or
+// a calendar invite, from its headers, or "" if it is not transactional
+func classifyMessage(headers map[string]string) string {
+	if strings.Contains(strings.ToLower(headers["content-class"]), "calendarmessage") ||
+		strings.Contains(strings.ToLower(headers["content-type"]), "text/calendar") {
+		return "calendar invite"
+	}
+	subject := strings.ToLower(headers["subject"])
+	for _, kind := range transactionalKinds {
+		if kind.subject.MatchString(subject) {
+			return kind.name
+		}
+	}
+	return ""
+}
+
+// Describe how many of a sender's emails are of each transactional kind, e.g.
+// "12 receipt, 3 shipping", or "" if none are
+func describeTransactional(sender SenderStats) string {
+	var parts []string
+	for _, kind := range transactionalKinds {
+		if n := len(sender.Transactional[kind.name]); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, kind.name))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Returns a copy of the sender's statistics without their transactional
+// emails, for deleting everything else
+func withoutTransactional(sender SenderStats) SenderStats {
+	kept := make(map[string]bool)
+	for _, ids := range sender.Transactional {
+		for _, id := range ids {
+			kept[id] = true
+		}
+	}
+	return withoutEmails(sender, kept)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// ANSI escape codes for the colours used in the output
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// A sender with at least this fraction of all scanned emails is shown in red
+const hugeSenderFraction = 0.05
+
+// Whether output should be coloured, set by setupColor
+var colorEnabled bool
+
+// Only colour output written to a terminal, so escape codes do not end up in
+// pipes and files. Colour can also be turned off with --no-color or NO_COLOR
+func setupColor() {
+	colorEnabled = !opts.NoColor && os.Getenv("NO_COLOR") == "" && isTerminal(display) && enableANSI(display.(*os.File))
+}
+
+// Returns true if the writer is a terminal
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Wrap text in the given colour, if colour is enabled
+func colorize(color, text string) string {
+	if !colorEnabled {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// Returns the colour a sender should be shown in: green for protected
+// senders, red for senders who make up a large part of the mailbox, and
+// yellow for newsletters. An empty string means no colour
+func senderColor(sender SenderStats, totalEmails int) string {
+	switch {
+	case isProtectedSender(sender):
+		return colorGreen
+	case totalEmails > 0 && float64(sender.Count) >= hugeSenderFraction*float64(totalEmails):
+		return colorRed
+	case sender.Newsletter:
+		return colorYellow
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Handles the 'completion bash|zsh|fish' command, which writes a shell
+// completion script to stdout. 'completion profiles' lists the saved profile
+// names, and is used by the scripts to complete --profile
+func runCompletionCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: completion bash|zsh|fish")
+	}
+
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout)
+	case "zsh":
+		writeZshCompletion(os.Stdout)
+	case "fish":
+		writeFishCompletion(os.Stdout)
+	case "profiles":
+		return writeProfileNames(os.Stdout)
+	default:
+		return fmt.Errorf("unknown shell %q, expected 'bash', 'zsh' or 'fish'", args[0])
+	}
+	return nil
+}
+
+// Print the name of every profile in the config file, one per line
+func writeProfileNames(w io.Writer) error {
+	config, err := loadConfig(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range profileNames(config) {
+		fmt.Fprintln(w, name)
+	}
+	return nil
+}
+
+// Returns every flag the program accepts
+func completionFlags() []*flag.Flag {
+	var flags []*flag.Flag
+	newFlagSet(&Options{}).VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	return flags
+}
+
+// Returns true if the flag is a boolean switch which takes no value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// Returns the names of every command
+func commandNames() []string {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Write a bash completion script
+func writeBashCompletion(w io.Writer) {
+	var flags []string
+	for _, f := range completionFlags() {
+		flags = append(flags, "--"+f.Name)
+	}
+
+	fmt.Fprintf(w, "_email_deleter() {\n")
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "    if [[ \"$prev\" == \"--profile\" ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"$(email_deleter completion profiles 2>/dev/null)\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(flags, " "))
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    case \"$prev\" in\n")
+	for _, c := range commands {
+		if len(c.subcommands) > 0 {
+			fmt.Fprintf(w, "        %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", c.name, strings.Join(c.subcommands, " "))
+		}
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    if [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(commandNames(), " "))
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o default -F _email_deleter email_deleter\n")
+}
+
+// Write a zsh completion script
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintf(w, "#compdef email_deleter\n\n")
+	fmt.Fprintf(w, "_email_deleter() {\n")
+	fmt.Fprintf(w, "    local -a flags\n")
+	fmt.Fprintf(w, "    flags=(\n")
+	for _, f := range completionFlags() {
+		fmt.Fprintf(w, "        '--%s:%s'\n", f.Name, strings.ReplaceAll(f.Usage, "'", "'\\''"))
+	}
+	fmt.Fprintf(w, "    )\n")
+	fmt.Fprintf(w, "    if [[ ${words[CURRENT-1]} == --profile ]]; then\n")
+	fmt.Fprintf(w, "        compadd -- ${(f)\"$(email_deleter completion profiles 2>/dev/null)\"}\n")
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    if [[ $PREFIX == -* ]]; then\n")
+	fmt.Fprintf(w, "        _describe 'flag' flags\n")
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    case ${words[CURRENT-1]} in\n")
+	for _, c := range commands {
+		if len(c.subcommands) > 0 {
+			fmt.Fprintf(w, "        %s) compadd -- %s; return ;;\n", c.name, strings.Join(c.subcommands, " "))
+		}
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    (( CURRENT == 2 )) && compadd -- %s\n", strings.Join(commandNames(), " "))
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "compdef _email_deleter email_deleter\n")
+}
+
+// Write a fish completion script
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "complete -c email_deleter -f\n")
+	fmt.Fprintf(w, "complete -c email_deleter -n __fish_use_subcommand -a '%s'\n", strings.Join(commandNames(), " "))
+	for _, c := range commands {
+		if len(c.subcommands) > 0 {
+			fmt.Fprintf(w, "complete -c email_deleter -n '__fish_seen_subcommand_from %s' -a '%s'\n", c.name, strings.Join(c.subcommands, " "))
+		}
+	}
+	for _, f := range completionFlags() {
+		description := strings.ReplaceAll(f.Usage, "'", "\\'")
+		switch {
+		case f.Name == "profile":
+			fmt.Fprintf(w, "complete -c email_deleter -l %s -d '%s' -x -a '(email_deleter completion profiles 2>/dev/null)'\n", f.Name, description)
+		case isBoolFlag(f):
+			fmt.Fprintf(w, "complete -c email_deleter -l %s -d '%s'\n", f.Name, description)
+		default:
+			fmt.Fprintf(w, "complete -c email_deleter -l %s -d '%s' -r\n", f.Name, description)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stores the defaults loaded from the config file. Any value
+// given on the command line overrides the value in here
+type Config struct {
+	Output           string                   `yaml:"output"`
+	Profile          string                   `yaml:"profile"`
+	Scopes           []string                 `yaml:"scopes"`
+	RateLimit        string                   `yaml:"rate_limit"`
+	ProtectedSenders []string                 `yaml:"protected_senders"`
+	PurgeAfter       string                   `yaml:"purge_after"`
+	ExcludeQuery     string                   `yaml:"exclude_query"`
+	Profiles         map[string]ProfileConfig `yaml:"profiles"`
+}
+
+// Stores where the credentials and token for one Gmail account are kept
+type ProfileConfig struct {
+	Credentials string `yaml:"credentials"`
+	Token       string `yaml:"token"`
+}
+
+// Returns the default location of the config file, ~/.config/email_deleter/config.yaml,
+// or %AppData%\email_deleter\config.yaml on Windows
+func defaultConfigPath() string {
+	if runtime.GOOS == "windows" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return ""
+		}
+		return filepath.Join(dir, "email_deleter", "config.yaml")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "email_deleter", "config.yaml")
+}
+
+// Read the config file at the given path. A missing file is
+// not an error, and just means there are no defaults to load
+func loadConfig(path string) (Config, error) {
+	var config Config
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	} else if err != nil {
+		return config, err
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// Fill in any options which were not given on the command line from the config file
+func applyConfig(config Config, flags *flag.FlagSet) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if !set["output"] && config.Output != "" {
+		opts.Output = config.Output
+	}
+	if !set["profile"] && config.Profile != "" {
+		opts.Profile = config.Profile
+	}
+	if !set["scopes"] && len(config.Scopes) > 0 {
+		opts.Scopes = config.Scopes
+	}
+	if !set["protect"] && len(config.ProtectedSenders) > 0 {
+		opts.Protected = config.ProtectedSenders
+	}
+	if !set["purge-after"] && config.PurgeAfter != "" {
+		opts.PurgeAfter = config.PurgeAfter
+	}
+	if !set["exclude-query"] && config.ExcludeQuery != "" {
+		opts.ExcludeQuery = config.ExcludeQuery
+	}
+	if !set["rate-limit"] && config.RateLimit != "" {
+		rateLimit, err := time.ParseDuration(config.RateLimit)
+		if err != nil {
+			return fmt.Errorf("invalid rate_limit in config file: %v", err)
+		}
+		opts.RateLimit = rateLimit
+	}
+
+	// Work out which files hold the selected profile's credentials and token.
+	// The default profile uses the files in the current directory
+	opts.CredentialsFile = "credentials.json"
+	opts.TokenFile = profileFile("token", ".json")
+	if profile, ok := config.Profiles[opts.Profile]; ok {
+		if profile.Credentials != "" {
+			opts.CredentialsFile = profile.Credentials
+		}
+		if profile.Token != "" {
+			opts.TokenFile = profile.Token
+		}
+	}
+
+	return nil
+}
+
+// Returns the name of a file belonging to the selected profile
+func profileFile(base, ext string) string {
+	return profileFileFor(opts.Profile, base, ext)
+}
+
+// Returns the name of a file belonging to the given profile. The default
+// profile uses base+ext, and other profiles use base-<profile>+ext
+func profileFileFor(profile, base, ext string) string {
+	if profile == "" || profile == "default" {
+		return base + ext
+	}
+	return base + "-" + profile + ext
+}
+
+// Returns the name of every profile in the config file, starting with the default profile
+func profileNames(config Config) []string {
+	names := []string{"default"}
+	for name := range config.Profiles {
+		if name != "default" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Returns true if the given sender is protected, so must never be offered up for deletion
+func isProtected(email string) bool {
+	for _, protected := range opts.Protected {
+		if strings.EqualFold(protected, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the sender is protected. A merged sender is protected if
+// any of the addresses it was merged from is
+func isProtectedSender(sender SenderStats) bool {
+	if isProtected(sender.Email) {
+		return true
+	}
+	for address := range sender.Aliases {
+		if isProtected(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse a comma separated flag value into a list, ignoring empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+// Set the options for the rest of the test, restoring the previous ones when it finishes
+func setOptions(t *testing.T, o Options) {
+	t.Helper()
+	previous := opts
+	opts = o
+	t.Cleanup(func() {
+		opts = previous
+	})
+}
+
+func TestIsProtectedSender(t *testing.T) {
+	tests := []struct {
+		name   string
+		sender SenderStats
+		want   bool
+	}{
+		{"protected address", SenderStats{Email: "alerts@bank.com"}, true},
+		{"protected address in another case", SenderStats{Email: "ALERTS@bank.com"}, true},
+		{"merged from a protected address", SenderStats{Email: "news@bank.com", Aliases: map[string]int{"news@bank.com": 3, "alerts@bank.com": 1}}, true},
+		{"unprotected address", SenderStats{Email: "news@bank.com"}, false},
+		{"merged from unprotected addresses", SenderStats{Email: "news@bank.com", Aliases: map[string]int{"news@bank.com": 3, "offers@bank.com": 1}}, false},
+	}
+	setOptions(t, Options{Protected: []string{"Alerts@Bank.com"}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isProtectedSender(tt.sender); got != tt.want {
+				t.Errorf("isProtectedSender(%v) = %t, want %t", tt.sender.Email, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// Terminals on other platforms understand ANSI escape codes already
+func enableANSI(file *os.File) bool {
+	return true
+}
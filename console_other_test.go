@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Terminals outside Windows need no setting up, so ANSI codes are always available
+func TestEnableANSI(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if !enableANSI(file) {
+		t.Error("enableANSI(file) = false, want true")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A file is not a console, so its mode cannot be changed and colour stays off
+func TestEnableANSINotAConsole(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if enableANSI(file) {
+		t.Error("enableANSI(file) = true, want false")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Layouts tried for Date headers which net/mail cannot parse. Mail clients
+// get the format wrong in many ways, e.g. leaving out the weekday or the
+// seconds, using numeric dates, or naming the zone instead of giving an offset
+var dateLayouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05",
+	"2 January 2006 15:04:05 -0700",
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2, 2006 15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"02/01/2006 15:04:05",
+}
+
+// Matches a leading word such as a weekday, which is often misspelt or
+// localised, and trailing comments such as "(UTC)", neither of which
+// affect the date. The leading word may be a month, so layouts are
+// tried both with and without it
+var (
+	leadingWord     = regexp.MustCompile(`^\p{L}+\.?,?\s+`)
+	trailingComment = regexp.MustCompile(`\s*\([^)]*\)\s*$`)
+)
+
+// Returns when an email was received. Gmail's internal date is used where
+// it is set, as it is always reliable. Otherwise the Date header is parsed,
+// and the zero time is returned if that cannot be parsed either
+func messageTime(message *gmail.Message) time.Time {
+	if message.InternalDate > 0 {
+		return time.UnixMilli(message.InternalDate)
+	}
+	return parseDateHeader(messageHeaders(message)["date"])
+}
+
+// Parse a Date header as leniently as possible, returning the zero time if it cannot be parsed
+func parseDateHeader(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+	if t, err := mail.ParseDate(value); err == nil {
+		return t
+	}
+
+	cleaned := strings.Join(strings.Fields(trailingComment.ReplaceAllString(value, "")), " ")
+	for _, candidate := range []string{cleaned, leadingWord.ReplaceAllString(cleaned, "")} {
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, candidate); err == nil {
+				return t
+			}
+		}
+	}
+	logger.Debug("Could not parse Date header", "date", value)
+	return time.Time{}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateHeader(t *testing.T) {
+	pacific := time.FixedZone("", -7*60*60)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Time
+	}{
+		{"RFC 5322", "Mon, 02 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 15, 4, 5, 0, pacific)},
+		{"trailing comment", "Mon, 2 Jan 2006 22:04:05 +0000 (UTC)", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"misspelt weekday", "Mnday, 2 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 15, 4, 5, 0, pacific)},
+		{"localised weekday", "Lundi 2 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 15, 4, 5, 0, pacific)},
+		{"no seconds", "2 Jan 2006 15:04 -0700", time.Date(2006, 1, 2, 15, 4, 0, 0, pacific)},
+		{"full month name", "2 January 2006 15:04:05 -0700", time.Date(2006, 1, 2, 15, 4, 5, 0, pacific)},
+		{"month first", "Jan 2, 2006 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"ISO 8601", "2006-01-02T15:04:05-07:00", time.Date(2006, 1, 2, 15, 4, 5, 0, pacific)},
+		{"numeric day first", "02/01/2006 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"extra spaces", "  2  Jan  2006  15:04:05  -0700 ", time.Date(2006, 1, 2, 15, 4, 5, 0, pacific)},
+		{"empty", "", time.Time{}},
+		{"not a date", "not a date", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDateHeader(tt.header); !got.Equal(tt.want) {
+				t.Errorf("parseDateHeader(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Number of matching emails previewed by the delete command before confirming
+const deletePreviewCount = 10
+
+// Handles the 'delete --query <search>' command, which deletes every email
+// matching a Gmail search (e.g. "from:foo older_than:2y has:attachment"),
+// moving it to the Trash unless --permanent was given, after showing how
+// many match and previewing the most recent.
+// Protected senders and the labels left out of the scan are excluded, as in
+// the interactive clean up
+func runDeleteCommand(ctx context.Context, srv *gmail.Service, args []string) error {
+	if len(args) > 0 || opts.Query == "" {
+		return fmt.Errorf("usage: delete --query <gmail search>")
+	}
+	scanTerms, _ := scanQuery()
+	terms := []string{scanTerms, "(" + opts.Query + ")"}
+	for _, sender := range opts.Protected {
+		terms = append(terms, "-from:"+sender)
+	}
+	ids, err := listMessageIds(ctx, srv, strings.TrimSpace(strings.Join(terms, " ")))
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "No emails match %q\n", opts.Query)
+		return nil
+	}
+
+	// Gmail lists the newest emails first, so the preview shows the most recent matches
+	fmt.Fprintf(display, "%d emails match %q. The most recent:\n", len(ids), opts.Query)
+	table := newTable("Date", "Sender", "Subject")
+	for _, id := range ids[:min(len(ids), deletePreviewCount)] {
+		if interrupted() {
+			return nil
+		}
+		message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders("From", "Subject", "Date"))
+		if err != nil {
+			logger.Warn("Could not get email metadata for preview", "id", id, "err", err)
+			continue
+		}
+		headers := messageHeaders(message)
+		subject := headers["subject"]
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		table.AddRow(formatDate(messageTime(message)), redactAddress(extractEmail(headers["from"])), redactSubject(subject))
+	}
+	table.Render(display)
+
+	fmt.Fprintf(display, "Would you like to delete all %d emails matching %q? (yes/no):\n", len(ids), opts.Query)
+	response, _ := readPrompt("confirm")
+	if strings.ToLower(response) != "yes" {
+		return nil
+	}
+	fmt.Fprintf(display, "Deleting %d emails...\n", len(ids))
+	if _, err := deleteEmails(ctx, srv, "query:"+opts.Query, ids); err != nil {
+		logger.Error("Error deleting emails matching query", "query", opts.Query, "err", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// One line of the --log deletion log, recording what happened to one message
+type DeletionLogRecord struct {
+	ID        string    `json:"id"`
+	Sender    string    `json:"sender"`
+	Subject   string    `json:"subject"`
+	Timestamp time.Time `json:"timestamp"`
+	Result    string    `json:"result"`
+}
+
+// Returns the subject of a message, for the deletion log. The subject is
+// only for the record, so failing to fetch it just leaves it empty
+func messageSubject(ctx context.Context, srv *gmail.Service, id string) string {
+	message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders("Subject"))
+	if err != nil {
+		logger.Warn("Could not get email subject for the deletion log", "id", id, "err", err)
+		return ""
+	}
+	return messageHeaders(message)["subject"]
+}
+
+// Append a record of what happened to a message to the deletion log, if
+// --log was given. Like the journal, the log is best effort
+func logDeletion(id, sender, subject, result string) {
+	if opts.DeletionLog == "" {
+		return
+	}
+
+	file, err := os.OpenFile(opts.DeletionLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		logger.Warn("Unable to open deletion log", "err", err)
+		return
+	}
+	defer file.Close()
+
+	record := DeletionLogRecord{ID: id, Sender: sender, Subject: subject, Timestamp: time.Now(), Result: result}
+	if err := json.NewEncoder(file).Encode(record); err != nil {
+		logger.Warn("Unable to write to deletion log", "err", err)
+	}
+}
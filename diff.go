@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// How one sender's email changed between two scans
+type SenderDiff struct {
+	Email    string `json:"email"`
+	Change   string `json:"change"`
+	OldCount int    `json:"old_count"`
+	NewCount int    `json:"new_count"`
+	OldSize  int64  `json:"old_size"`
+	NewSize  int64  `json:"new_size"`
+}
+
+// JSON record comparing two scans, written by the diff command
+type DiffRecord struct {
+	Type    string       `json:"type"`
+	Old     time.Time    `json:"old"`
+	New     time.Time    `json:"new"`
+	Senders []SenderDiff `json:"senders"`
+}
+
+// Handles the 'diff <old> [new]' command, which compares two saved scans and
+// shows which senders grew, shrank, appeared or disappeared in between. The
+// new scan defaults to the profile's current snapshot
+func runDiffCommand(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: diff <old snapshot> [new snapshot]")
+	}
+	newPath := snapshotPath()
+	if len(args) == 2 {
+		newPath = args[1]
+	}
+
+	oldSnapshot, err := loadSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+	newSnapshot, err := loadSnapshot(newPath)
+	if err != nil {
+		return err
+	}
+	if oldSnapshot.Partial || newSnapshot.Partial {
+		logger.Warn("One of the snapshots is from an interrupted scan, so senders may appear to have changed when they were not scanned")
+	}
+
+	diffs := diffSnapshots(oldSnapshot.Senders, newSnapshot.Senders)
+	if jsonOutput() {
+		for i := range diffs {
+			diffs[i].Email = redactAddress(diffs[i].Email)
+		}
+		emitJSON(DiffRecord{Type: "diff", Old: oldSnapshot.CreatedAt, New: newSnapshot.CreatedAt, Senders: diffs})
+		return nil
+	}
+	printDiff(oldSnapshot, newSnapshot, diffs)
+	return nil
+}
+
+// Compare the senders of two scans, returning every sender whose email
+// changed, with the biggest changes first
+func diffSnapshots(oldSenders, newSenders []SenderStats) []SenderDiff {
+	if !opts.KeepAliases {
+		oldSenders = mergeAliases(oldSenders)
+		newSenders = mergeAliases(newSenders)
+	}
+
+	diffMap := make(map[string]*SenderDiff)
+	for _, sender := range oldSenders {
+		diffMap[sender.Email] = &SenderDiff{Email: sender.Email, OldCount: sender.Count, OldSize: sender.Size}
+	}
+	for _, sender := range newSenders {
+		diff, exists := diffMap[sender.Email]
+		if !exists {
+			diff = &SenderDiff{Email: sender.Email}
+			diffMap[sender.Email] = diff
+		}
+		diff.NewCount = sender.Count
+		diff.NewSize = sender.Size
+	}
+
+	var diffs []SenderDiff
+	for _, diff := range diffMap {
+		switch {
+		case diff.OldCount == 0 && diff.NewCount > 0:
+			diff.Change = "appeared"
+		case diff.OldCount > 0 && diff.NewCount == 0:
+			diff.Change = "disappeared"
+		case diff.NewCount > diff.OldCount:
+			diff.Change = "grew"
+		case diff.NewCount < diff.OldCount:
+			diff.Change = "shrank"
+		default:
+			continue
+		}
+		diffs = append(diffs, *diff)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		a, b := abs(diffs[i].NewCount-diffs[i].OldCount), abs(diffs[j].NewCount-diffs[j].OldCount)
+		if a != b {
+			return a > b
+		}
+		return diffs[i].Email < diffs[j].Email
+	})
+	return diffs
+}
+
+// Print a summary of the changes between two scans, and every sender which changed
+func printDiff(oldSnapshot, newSnapshot Snapshot, diffs []SenderDiff) {
+	changes := make(map[string]int)
+	oldTotal, newTotal := 0, 0
+	for _, sender := range oldSnapshot.Senders {
+		oldTotal += sender.Count
+	}
+	for _, sender := range newSnapshot.Senders {
+		newTotal += sender.Count
+	}
+	for _, diff := range diffs {
+		changes[diff.Change]++
+	}
+
+	fmt.Fprintf(display, "\nBetween %s and %s the mailbox went from %d to %d emails (%+d)\n",
+		formatDate(oldSnapshot.CreatedAt), formatDate(newSnapshot.CreatedAt), oldTotal, newTotal, newTotal-oldTotal)
+	fmt.Fprintf(display, "%d senders appeared, %d disappeared, %d grew and %d shrank\n",
+		changes["appeared"], changes["disappeared"], changes["grew"], changes["shrank"])
+	if len(diffs) == 0 {
+		return
+	}
+
+	table := newTable("#", "Sender", "Change", "Before", "After", "Difference", "Size difference").AlignRight(0, 3, 4, 5, 6)
+	for i, diff := range diffs {
+		sizeChange := formatSize(abs(diff.NewSize - diff.OldSize))
+		if diff.NewSize < diff.OldSize {
+			sizeChange = "-" + sizeChange
+		} else {
+			sizeChange = "+" + sizeChange
+		}
+		table.AddRow(strconv.Itoa(i+1), redactAddress(diff.Email), diff.Change, strconv.Itoa(diff.OldCount), strconv.Itoa(diff.NewCount),
+			fmt.Sprintf("%+d", diff.NewCount-diff.OldCount), sizeChange)
+	}
+	table.Render(display)
+}
+
+// Returns the absolute value of n
+func abs[T int | int64 | time.Duration](n T) T {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Age buckets used in the domain report, as the age in months each one starts at
+var ageBuckets = []struct {
+	name   string
+	months int
+}{
+	{"under 1 month", 0},
+	{"1-6 months", 1},
+	{"6-12 months", 6},
+	{"1-2 years", 12},
+	{"over 2 years", 24},
+}
+
+// Breakdown of one domain's email by sender, for the domain report
+type DomainReport struct {
+	Domain  string         `json:"domain"`
+	Count   int            `json:"count"`
+	Size    int64          `json:"size"`
+	Senders []SenderReport `json:"senders"`
+}
+
+// Breakdown of one sender's email by age, for the domain report
+type SenderReport struct {
+	Email string      `json:"email"`
+	Count int         `json:"count"`
+	Size  int64       `json:"size"`
+	Ages  []AgeReport `json:"ages"`
+}
+
+// Number and size of a sender's emails in one age bucket
+type AgeReport struct {
+	Age   string `json:"age"`
+	Count int    `json:"count"`
+	Size  int64  `json:"size"`
+}
+
+// Handles the 'domains [file]' command, which writes the scan as nested JSON
+// broken down by domain, then sender, then age, for analysis in other tools.
+// Senders grouped by --min-count are reported as "others" in their domain
+func runDomainsCommand(senderStats []SenderStats, args []string, now time.Time) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: domains [file]")
+	}
+
+	var w io.Writer = os.Stdout
+	if len(args) == 1 {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(domainReports(senderStats, now)); err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		fmt.Fprintf(display, "Wrote the domain report to %s\n", args[0])
+	}
+	return nil
+}
+
+// Group the senders by domain, largest first, with each sender's email broken down by age
+func domainReports(senderStats []SenderStats, now time.Time) []DomainReport {
+	domainMap := make(map[string]*DomainReport)
+	othersMap := make(map[string]*SenderReport)
+	for _, sender := range senderStats {
+		_, domain := splitAddress(sender.Email)
+		report, exists := domainMap[domain]
+		if !exists {
+			report = &DomainReport{Domain: domain}
+			domainMap[domain] = report
+		}
+		report.Count += sender.Count
+		report.Size += sender.Size
+
+		// Small senders are merged into the domain's others entry, as in the prompts
+		if opts.MinCount > 0 && sender.Count < opts.MinCount {
+			others, exists := othersMap[domain]
+			if !exists {
+				others = &SenderReport{Email: "others", Ages: newAgeReports()}
+				othersMap[domain] = others
+			}
+			addSenderReport(others, sender, now)
+			continue
+		}
+		senderReport := SenderReport{Email: redactAddress(sender.Email), Ages: newAgeReports()}
+		addSenderReport(&senderReport, sender, now)
+		report.Senders = append(report.Senders, senderReport)
+	}
+
+	var reports []DomainReport
+	for domain, report := range domainMap {
+		sort.Slice(report.Senders, func(i, j int) bool {
+			return report.Senders[i].Count > report.Senders[j].Count
+		})
+		if others, ok := othersMap[domain]; ok {
+			report.Senders = append(report.Senders, *others)
+		}
+		reports = append(reports, *report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Count > reports[j].Count
+	})
+	return reports
+}
+
+// Returns an empty report for every age bucket
+func newAgeReports() []AgeReport {
+	var ages []AgeReport
+	for _, bucket := range ageBuckets {
+		ages = append(ages, AgeReport{Age: bucket.name})
+	}
+	return ages
+}
+
+// Add a sender's emails to a sender report, sorting them into age buckets by the month they arrived
+func addSenderReport(report *SenderReport, sender SenderStats, now time.Time) {
+	report.Count += sender.Count
+	report.Size += sender.Size
+	for month, count := range sender.MonthlyCount {
+		received, err := time.Parse("2006-01", month)
+		if err != nil {
+			continue
+		}
+		age := (now.Year()-received.Year())*12 + int(now.Month()-received.Month())
+		bucket := 0
+		for i, b := range ageBuckets {
+			if age >= b.months {
+				bucket = i
+			}
+		}
+		report.Ages[bucket].Count += count
+		report.Ages[bucket].Size += sender.MonthlyBytes[month]
+	}
+}
+
+// Roll the senders up into one entry per sending domain, named "@domain",
+// for --group-by domain. The senders making up each domain are returned
+// too, keyed by the domain entry's name, so the user can drill down into them.
+// Protected senders are left out, so deleting a domain never touches their emails
+func groupByDomain(senderStats []SenderStats) ([]SenderStats, map[string][]SenderStats) {
+	groupMap := make(map[string]*SenderStats)
+	members := make(map[string][]SenderStats)
+	var order []string
+	for _, sender := range senderStats {
+		if isProtectedSender(sender) {
+			continue
+		}
+		_, domain := splitAddress(sender.Email)
+		name := "@" + domain
+		group, exists := groupMap[name]
+		if !exists {
+			group = &SenderStats{
+				Email:        name,
+				MonthlyBytes: make(map[string]int64),
+				MonthlyCount: make(map[string]int),
+			}
+			groupMap[name] = group
+			order = append(order, name)
+		}
+		mergeSender(group, sender)
+		members[name] = append(members[name], sender)
+	}
+
+	var groups []SenderStats
+	for _, name := range order {
+		groups = append(groups, *groupMap[name])
+	}
+	return groups, members
+}
+
+// Add one sender's statistics to a group of senders
+func mergeSender(group *SenderStats, sender SenderStats) {
+	group.Count += sender.Count
+	group.Ids = append(group.Ids, sender.Ids...)
+	group.Duplicates = append(group.Duplicates, sender.Duplicates...)
+	group.Threads = append(group.Threads, sender.Threads...)
+	group.Size += sender.Size
+	group.Unread += sender.Unread
+	group.ArchivedUnread += sender.ArchivedUnread
+	group.Direct += sender.Direct
+	group.CC += sender.CC
+	group.Replies += sender.Replies
+	group.RepliesChecked = sender.RepliesChecked
+	group.Attachments += sender.Attachments
+	group.AttachmentSize += sender.AttachmentSize
+	group.Newsletter = group.Newsletter || sender.Newsletter
+	group.Automated = group.Automated || sender.Automated
+	group.Bounces += sender.Bounces
+	// Arrival times are not kept, so a group takes the pattern of its most regular member
+	if sender.Regularity > group.Regularity {
+		group.Period, group.Regularity = sender.Period, sender.Regularity
+	}
+	if group.DisplayName == "" {
+		group.DisplayName = sender.DisplayName
+	}
+	if group.ListID == "" {
+		group.ListID = sender.ListID
+	}
+	if group.FirstSeen.IsZero() || (!sender.FirstSeen.IsZero() && sender.FirstSeen.Before(group.FirstSeen)) {
+		group.FirstSeen = sender.FirstSeen
+	}
+	if sender.LastSeen.After(group.LastSeen) {
+		group.LastSeen = sender.LastSeen
+	}
+	for month, bytes := range sender.MonthlyBytes {
+		group.MonthlyBytes[month] += bytes
+	}
+	for month, count := range sender.MonthlyCount {
+		group.MonthlyCount[month] += count
+	}
+	for id, size := range sender.MessageSizes {
+		if group.MessageSizes == nil {
+			group.MessageSizes = make(map[string]int64)
+		}
+		group.MessageSizes[id] = size
+	}
+	for id, received := range sender.MessageTimes {
+		if group.MessageTimes == nil {
+			group.MessageTimes = make(map[string]int64)
+		}
+		group.MessageTimes[id] = received
+	}
+	for label, count := range sender.Labels {
+		if group.Labels == nil {
+			group.Labels = make(map[string]int)
+		}
+		group.Labels[label] += count
+	}
+	for label, counts := range sender.LabelAges {
+		if group.LabelAges == nil {
+			group.LabelAges = make(map[string][]int)
+		}
+		if group.LabelAges[label] == nil {
+			group.LabelAges[label] = make([]int, len(retentionBuckets))
+		}
+		for i, count := range counts {
+			group.LabelAges[label][i] += count
+		}
+	}
+	for kind, ids := range sender.Transactional {
+		if group.Transactional == nil {
+			group.Transactional = make(map[string][]string)
+		}
+		group.Transactional[kind] = append(group.Transactional[kind], ids...)
+	}
+	for subject, count := range sender.Subjects {
+		if group.Subjects == nil {
+			group.Subjects = make(map[string]int)
+		}
+		group.Subjects[subject] += count
+	}
+	for label, size := range sender.CategorySizes {
+		if group.CategorySizes == nil {
+			group.CategorySizes = make(map[string]int64)
+		}
+		group.CategorySizes[label] += size
+	}
+	for profile, count := range sender.Accounts {
+		if group.Accounts == nil {
+			group.Accounts = make(map[string]int)
+		}
+		group.Accounts[profile] += count
+	}
+	for account, count := range sender.ForwardedFrom {
+		if group.ForwardedFrom == nil {
+			group.ForwardedFrom = make(map[string]int)
+		}
+		group.ForwardedFrom[account] += count
+	}
+	for day := range sender.Heatmap {
+		for hour, count := range sender.Heatmap[day] {
+			group.Heatmap[day][hour] += count
+		}
+	}
+}
+
+// Print the senders making up a domain, for drilling down into it from the prompt
+func printDomainMembers(domain string, members []SenderStats) {
+	fmt.Fprintf(display, "Senders from %s:\n", domain)
+	table := newTable("Sender", "Emails", "Size").AlignRight(1, 2)
+	for _, sender := range members {
+		table.AddRow(redactAddress(sender.Email), strconv.Itoa(sender.Count), formatSize(sender.Size))
+	}
+	table.Render(display)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGroupByDomain(t *testing.T) {
+	senders := []SenderStats{
+		{Email: "news@store.com", Count: 2, Size: 200, Ids: []string{"n1", "n2"}, Aliases: map[string]int{"news@store.com": 1, "promo@store.com": 1}},
+		{Email: "alerts@bank.com", Count: 1, Size: 50, Ids: []string{"b1"}},
+		{Email: "deals@store.com", Count: 3, Size: 300, Ids: []string{"d1", "d2", "d3"}},
+		{Email: "alex@example.org", Count: 1, Size: 10, Ids: []string{"f1"}},
+	}
+	type group struct {
+		name    string
+		ids     []string
+		size    int64
+		members int
+	}
+	tests := []struct {
+		name      string
+		protected []string
+		want      []group
+	}{
+		{
+			name: "no protected senders",
+			want: []group{
+				{"@store.com", []string{"n1", "n2", "d1", "d2", "d3"}, 500, 2},
+				{"@bank.com", []string{"b1"}, 50, 1},
+				{"@example.org", []string{"f1"}, 10, 1},
+			},
+		},
+		{
+			name:      "protected sender is left out",
+			protected: []string{"alerts@bank.com"},
+			want: []group{
+				{"@store.com", []string{"n1", "n2", "d1", "d2", "d3"}, 500, 2},
+				{"@example.org", []string{"f1"}, 10, 1},
+			},
+		},
+		{
+			name:      "sender merged from a protected address is left out",
+			protected: []string{"promo@store.com"},
+			want: []group{
+				{"@bank.com", []string{"b1"}, 50, 1},
+				{"@store.com", []string{"d1", "d2", "d3"}, 300, 1},
+				{"@example.org", []string{"f1"}, 10, 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, Options{Protected: tt.protected})
+			groups, members := groupByDomain(senders)
+			if len(groups) != len(tt.want) {
+				t.Fatalf("groupByDomain() returned %d domains, want %d", len(groups), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := groups[i]
+				if got.Email != want.name || !slices.Equal(got.Ids, want.ids) || got.Count != len(want.ids) || got.Size != want.size {
+					t.Errorf("domain %d = %s %v (%d emails, %d bytes), want %s %v (%d emails, %d bytes)",
+						i, got.Email, got.Ids, got.Count, got.Size, want.name, want.ids, len(want.ids), want.size)
+				}
+				if len(members[want.name]) != want.members {
+					t.Errorf("%s has %d members, want %d", want.name, len(members[want.name]), want.members)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Returns a key which is the same for every copy of an email. The Message-ID
+// header is used where there is one, otherwise copies are matched on their
+// sender, subject, date and size. Copies are common after migrating mail
+// between accounts, and with forwarding loops
+func duplicateKey(message *gmail.Message, sender string) string {
+	headers := messageHeaders(message)
+	if id := strings.TrimSpace(headers["message-id"]); id != "" {
+		return "id:" + id
+	}
+	return fmt.Sprintf("%s|%s|%s|%d", sender, headers["subject"], headers["date"], message.SizeEstimate)
+}
+
+// Handles the 'duplicates' command, which lists the senders with duplicate
+// copies of emails in the mailbox, and offers to delete every copy but one
+func runDuplicatesCommand(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) error {
+	var senders []SenderStats
+	total := 0
+	for _, sender := range senderStats {
+		if len(sender.Duplicates) > 0 {
+			senders = append(senders, sender)
+			total += len(sender.Duplicates)
+		}
+	}
+	if len(senders) == 0 {
+		fmt.Fprintf(display, "No duplicate emails were found\n")
+		return nil
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return len(senders[i].Duplicates) > len(senders[j].Duplicates)
+	})
+
+	fmt.Fprintf(display, "\nFound %d duplicate copies of emails from %d senders:\n", total, len(senders))
+	// Copies are assumed to be the size of the sender's average email
+	table := newTable("#", "Sender", "Emails", "Duplicate copies", "Estimated size").AlignRight(0, 2, 3, 4)
+	for i, sender := range senders {
+		table.AddRow(strconv.Itoa(i+1), redactAddress(sender.Email), strconv.Itoa(sender.Count), strconv.Itoa(len(sender.Duplicates)),
+			formatSize(sender.Size*int64(len(sender.Duplicates))/int64(max(sender.Count, 1))))
+	}
+	table.Render(display)
+
+	for {
+		fmt.Fprintf(display, "Which senders' duplicate copies would you like to delete, keeping one copy of each email? (all/none/numbers e.g. 1,3-5):\n")
+		response, _ := readPrompt("select")
+		response = strings.ToLower(response)
+		if response == "none" || response == "" {
+			return nil
+		}
+
+		var chosen []SenderStats
+		if response == "all" {
+			chosen = senders
+		} else {
+			selected, err := parseSelection(response, len(senders))
+			if err != nil {
+				fmt.Fprintf(display, "Invalid selection: %v\n", err)
+				continue
+			}
+			for _, n := range selected {
+				chosen = append(chosen, senders[n-1])
+			}
+		}
+
+		for _, sender := range chosen {
+			fmt.Fprintf(display, "Deleting %d duplicate copies from %s...\n", len(sender.Duplicates), redactAddress(sender.Email))
+			if _, err := deleteEmails(ctx, srv, sender.Email, sender.Duplicates); err != nil {
+				logger.Error("Error deleting duplicate emails", "sender", sender.Email, "err", err)
+			}
+			if interrupted() {
+				return nil
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// An email in the fake mailbox
+type fakeMessage struct {
+	ID         string
+	ThreadID   string
+	From       string
+	Subject    string
+	Received   time.Time
+	Size       int64
+	Labels     []string
+	Attachment bool
+}
+
+// A fake of the Gmail API endpoints the tool calls, serving a scripted
+// mailbox held in memory. Every request made by the tool, including the
+// token check, is sent to it instead of Google
+type fakeGmail struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	messages []*fakeMessage
+	calls    map[string]int
+}
+
+// Number of emails returned per page by messages.list, kept small so paging is exercised
+const fakePageSize = 3
+
+// Start a fake Gmail server holding the given emails, and send every
+// HTTP request made by the test to it until the test finishes
+func newFakeGmail(t *testing.T, messages ...*fakeMessage) *fakeGmail {
+	t.Helper()
+	fake := &fakeGmail{t: t, messages: messages, calls: make(map[string]int)}
+	server := httptest.NewServer(fake)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return server.Client().Transport.RoundTrip(req)
+	})
+	t.Cleanup(func() {
+		http.DefaultTransport = original
+		server.Close()
+	})
+	return fake
+}
+
+// Adapts a function to an http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Route a request to the handler for the Gmail method it calls
+func (f *fakeGmail) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/tokeninfo" {
+		f.writeJSON(w, map[string]string{"scope": gmail.MailGoogleComScope, "email": "me@example.com"})
+		return
+	}
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/gmail/v1/users/me/")
+	if !ok {
+		f.fail(w, r)
+		return
+	}
+	parts := strings.Split(path, "/")
+	method := r.Method + " " + parts[0]
+	if len(parts) > 1 {
+		method += "/" + parts[len(parts)-1]
+		if len(parts) == 2 && parts[1] != "batchModify" && parts[1] != "batchDelete" {
+			method = r.Method + " " + parts[0] + "/{id}"
+		}
+	}
+	f.calls[method]++
+
+	switch method {
+	case "GET profile":
+		f.writeJSON(w, &gmail.Profile{EmailAddress: "me@example.com", MessagesTotal: int64(len(f.messages)), HistoryId: 1})
+	case "GET history":
+		f.writeJSON(w, &gmail.ListHistoryResponse{HistoryId: 1})
+	case "GET labels":
+		f.writeJSON(w, &gmail.ListLabelsResponse{Labels: f.labels()})
+	case "GET labels/{id}":
+		f.getLabel(w, r, parts[1])
+	case "GET messages":
+		f.listMessages(w, r)
+	case "GET messages/{id}":
+		if message := f.find(parts[1]); message != nil {
+			f.writeJSON(w, message.gmailMessage())
+		} else {
+			http.NotFound(w, r)
+		}
+	case "POST messages/batchModify":
+		var req gmail.BatchModifyMessagesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			f.t.Errorf("could not decode batchModify: %v", err)
+		}
+		for _, id := range req.Ids {
+			if message := f.find(id); message != nil {
+				message.relabel(req.AddLabelIds, req.RemoveLabelIds)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case "POST messages/trash", "POST messages/untrash":
+		message := f.find(parts[1])
+		if message == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if parts[2] == "trash" {
+			message.relabel([]string{"TRASH"}, nil)
+		} else {
+			message.relabel(nil, []string{"TRASH"})
+		}
+		f.writeJSON(w, message.gmailMessage())
+	default:
+		f.fail(w, r)
+	}
+}
+
+// Fail the test for a request the fake does not handle, so gaps in the fake show up
+func (f *fakeGmail) fail(w http.ResponseWriter, r *http.Request) {
+	f.t.Errorf("fake Gmail does not handle %s %s", r.Method, r.URL.Path)
+	http.Error(w, "not implemented by the fake", http.StatusNotImplemented)
+}
+
+// Write a response as JSON
+func (f *fakeGmail) writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		f.t.Errorf("could not encode response: %v", err)
+	}
+}
+
+// Returns the email with the given ID, or nil if there is none
+func (f *fakeGmail) find(id string) *fakeMessage {
+	for _, message := range f.messages {
+		if message.ID == id {
+			return message
+		}
+	}
+	return nil
+}
+
+// Returns every label used in the mailbox
+func (f *fakeGmail) labels() []*gmail.Label {
+	var names []string
+	for _, message := range f.messages {
+		for _, label := range message.Labels {
+			if !slices.Contains(names, label) {
+				names = append(names, label)
+			}
+		}
+	}
+	for _, system := range []string{"INBOX", "TRASH", "SPAM", "STARRED", "IMPORTANT", "UNREAD"} {
+		if !slices.Contains(names, system) {
+			names = append(names, system)
+		}
+	}
+	var labels []*gmail.Label
+	for _, name := range names {
+		labels = append(labels, &gmail.Label{Id: name, Name: name, Type: "system"})
+	}
+	return labels
+}
+
+// Handle labels.get, counting the emails with the label
+func (f *fakeGmail) getLabel(w http.ResponseWriter, r *http.Request, id string) {
+	label := &gmail.Label{Id: id, Name: id, Type: "system"}
+	for _, message := range f.messages {
+		if slices.Contains(message.Labels, id) {
+			label.MessagesTotal++
+		}
+	}
+	f.writeJSON(w, label)
+}
+
+// Handle messages.list, filtering the mailbox by the search query and labels
+// and returning it a page at a time, newest first like Gmail
+func (f *fakeGmail) listMessages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	terms := searchTerms(query.Get("q"))
+	includeSpamTrash := query.Get("includeSpamTrash") == "true"
+
+	var matching []*gmail.Message
+	for _, message := range f.messages {
+		if !includeSpamTrash && (slices.Contains(message.Labels, "SPAM") || slices.Contains(message.Labels, "TRASH")) {
+			continue
+		}
+		if !message.hasLabels(query["labelIds"]) {
+			continue
+		}
+		if message.matches(f.t, terms) {
+			matching = append(matching, &gmail.Message{Id: message.ID, ThreadId: message.ThreadID})
+		}
+	}
+	slices.SortStableFunc(matching, func(a, b *gmail.Message) int {
+		return f.find(b.Id).Received.Compare(f.find(a.Id).Received)
+	})
+
+	start, _ := strconv.Atoi(query.Get("pageToken"))
+	end := min(start+fakePageSize, len(matching))
+	response := &gmail.ListMessagesResponse{Messages: matching[start:end], ResultSizeEstimate: int64(len(matching))}
+	if end < len(matching) {
+		response.NextPageToken = strconv.Itoa(end)
+	}
+	f.writeJSON(w, response)
+}
+
+// Split a Gmail search into its terms, keeping {...} groups together
+func searchTerms(query string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range query + " " {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}':
+			depth--
+		case r == ' ' && depth == 0:
+			if i > start {
+				terms = append(terms, query[start:i])
+			}
+			start = i + 1
+		}
+	}
+	return terms
+}
+
+// Returns true if the email has every one of the labels
+func (m *fakeMessage) hasLabels(labels []string) bool {
+	for _, label := range labels {
+		if !slices.Contains(m.Labels, label) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the email matches every search term. Only the terms the
+// tool's searches use are understood, and any other fails the test
+func (m *fakeMessage) matches(t *testing.T, terms []string) bool {
+	for _, term := range terms {
+		if group, ok := strings.CutPrefix(term, "{"); ok {
+			if !slices.ContainsFunc(searchTerms(strings.TrimSuffix(group, "}")), func(inner string) bool {
+				return m.matches(t, []string{inner})
+			}) {
+				return false
+			}
+			continue
+		}
+
+		negated := strings.HasPrefix(term, "-")
+		operator, value, _ := strings.Cut(strings.TrimPrefix(term, "-"), ":")
+		var match bool
+		switch operator {
+		case "in", "label", "is":
+			label := strings.ToUpper(value)
+			if label == "DRAFTS" {
+				label = "DRAFT"
+			} else if label == "CHATS" {
+				label = "CHAT"
+			}
+			match = slices.Contains(m.Labels, label)
+		case "from":
+			match = strings.Contains(strings.ToLower(m.From), strings.ToLower(value))
+		case "has":
+			match = value == "attachment" && m.Attachment
+		default:
+			t.Errorf("fake Gmail does not understand the search term %q", term)
+		}
+		if match == negated {
+			return false
+		}
+	}
+	return true
+}
+
+// Add and remove labels on the email
+func (m *fakeMessage) relabel(add, remove []string) {
+	for _, label := range add {
+		if !slices.Contains(m.Labels, label) {
+			m.Labels = append(m.Labels, label)
+		}
+	}
+	m.Labels = slices.DeleteFunc(m.Labels, func(label string) bool {
+		return slices.Contains(remove, label)
+	})
+}
+
+// Returns the email as the API returns it with the metadata format
+func (m *fakeMessage) gmailMessage() *gmail.Message {
+	return &gmail.Message{
+		Id:           m.ID,
+		ThreadId:     m.ThreadID,
+		LabelIds:     slices.Clone(m.Labels),
+		SizeEstimate: m.Size,
+		InternalDate: m.Received.UnixMilli(),
+		Snippet:      m.Subject,
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			{Name: "From", Value: m.From},
+			{Name: "To", Value: "me@example.com"},
+			{Name: "Subject", Value: m.Subject},
+			{Name: "Date", Value: m.Received.Format(time.RFC1123Z)},
+			{Name: "Message-ID", Value: "<" + m.ID + "@example.com>"},
+		}},
+	}
+}
+
+// Returns true if the email is in the Trash
+func (m *fakeMessage) trashed() bool {
+	return slices.Contains(m.Labels, "TRASH")
+}
+
+// Run the tool in-process with the given arguments, as if from the command
+// line, answering its prompts with the given lines of input. Each run starts
+// from the same state as a new process, with its files in a temporary
+// directory. Returns the exit code and everything written to the display
+func runScenario(t *testing.T, input string, args ...string) (int, string) {
+	t.Helper()
+
+	opts = Options{}
+	answers = nil
+	permanentConfirmed = false
+	failedMessages = 0
+	freedBytes = 0
+	runFailure = ""
+	labelIDs, labelNames = nil, nil
+	keptMessages, keptThreads = nil, nil
+	retriesUsed, quotaUnits = 0, 0
+	stdin = bufio.NewReader(strings.NewReader(input))
+
+	var output bytes.Buffer
+	display = &output
+	t.Cleanup(func() {
+		display = os.Stdout
+		stdin = bufio.NewReader(os.Stdin)
+	})
+
+	dir := t.TempDir()
+	token := filepath.Join(dir, "token.txt")
+	if err := os.WriteFile(token, []byte("fake-access-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Args = append([]string{"email_deleter"}, args...)
+	os.Args = append(os.Args, "--access-token", token, "--config", filepath.Join(dir, "config.yaml"),
+		"--log-file", filepath.Join(dir, "log.txt"), "--rate-limit", "0", "--no-color", "--quiet", "--preview", "0")
+	return run(), output.String()
+}
+
+// Change to a temporary directory for the rest of the test, as the journal,
+// cache and snapshot are kept in the working directory
+func inTempDir(t *testing.T) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(previous)
+	})
+}
+
+// Returns a scripted mailbox: a newsletter which sends a lot, one of whose
+// emails is starred, and a friend who sends a little
+func seededMailbox() []*fakeMessage {
+	now := time.Now()
+	var messages []*fakeMessage
+	for i := range 4 {
+		message := &fakeMessage{
+			ID:       fmt.Sprintf("news%d", i),
+			ThreadID: fmt.Sprintf("news-thread%d", i),
+			From:     "Store News <news@store.example>",
+			Subject:  fmt.Sprintf("Weekly deals %d", i),
+			Received: now.AddDate(0, 0, -7*i),
+			Size:     10000,
+			Labels:   []string{"INBOX", "CATEGORY_PROMOTIONS", "UNREAD"},
+		}
+		if i == 2 {
+			message.Labels = append(message.Labels, "STARRED")
+		}
+		messages = append(messages, message)
+	}
+	messages = append(messages, &fakeMessage{
+		ID:       "friend0",
+		ThreadID: "friend-thread0",
+		From:     "Alex <alex@example.org>",
+		Subject:  "Dinner on Friday?",
+		Received: now.AddDate(0, 0, -1),
+		Size:     3000,
+		Labels:   []string{"INBOX"},
+	})
+	return messages
+}
+
+// Scan the mailbox, delete the newsletter at the prompt and keep the friend,
+// then undo the run and check everything is back where it was
+func TestScenarioScanDeleteUndo(t *testing.T) {
+	inTempDir(t)
+	fake := newFakeGmail(t, seededMailbox()...)
+
+	code, output := runScenario(t, "yes\nno\n")
+	if code != 0 {
+		t.Fatalf("clean up exited with %d:\n%s", code, output)
+	}
+	for _, message := range fake.messages {
+		// The starred email is kept, as --keep-starred is on by default
+		want := strings.HasPrefix(message.ID, "news") && message.ID != "news2"
+		if message.trashed() != want {
+			t.Errorf("%s trashed = %t, want %t", message.ID, message.trashed(), want)
+		}
+	}
+	if !strings.Contains(output, "Successfully deleted 3 emails") {
+		t.Errorf("output does not report the 3 emails deleted:\n%s", output)
+	}
+
+	entries, err := readJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("journal has %d entries, want 3", len(entries))
+	}
+
+	code, output = runScenario(t, "", "undo")
+	if code != 0 {
+		t.Fatalf("undo exited with %d:\n%s", code, output)
+	}
+	for _, message := range fake.messages {
+		if message.trashed() {
+			t.Errorf("%s is still in the Trash after undo", message.ID)
+		}
+	}
+	if fake.calls["POST messages/untrash"] != 3 {
+		t.Errorf("undo restored %d emails, want 3", fake.calls["POST messages/untrash"])
+	}
+}
+
+// Drive the clean up from an answers file, with nothing on stdin, and check
+// only the senders answered yes are deleted
+func TestScenarioAnswersFile(t *testing.T) {
+	inTempDir(t)
+	fake := newFakeGmail(t, seededMailbox()...)
+
+	path := filepath.Join(t.TempDir(), "answers.txt")
+	if err := os.WriteFile(path, []byte("alex@example.org=yes\n*=no\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	code, output := runScenario(t, "", "--answers", path)
+	if code != 0 {
+		t.Fatalf("clean up exited with %d:\n%s", code, output)
+	}
+	for _, message := range fake.messages {
+		if want := message.ID == "friend0"; message.trashed() != want {
+			t.Errorf("%s trashed = %t, want %t", message.ID, message.trashed(), want)
+		}
+	}
+}
+
+// Scan a second time and check the emails fetched by the first scan are read
+// from the cache rather than fetched again
+func TestScenarioRescanUsesCache(t *testing.T) {
+	inTempDir(t)
+	fake := newFakeGmail(t, seededMailbox()...)
+
+	if code, output := runScenario(t, "", "scan"); code != 0 {
+		t.Fatalf("first scan exited with %d:\n%s", code, output)
+	}
+	fetched := fake.calls["GET messages/{id}"]
+	if fetched < len(fake.messages) {
+		t.Fatalf("first scan fetched %d emails, want at least %d", fetched, len(fake.messages))
+	}
+
+	code, output := runScenario(t, "", "scan")
+	if code != 0 {
+		t.Fatalf("second scan exited with %d:\n%s", code, output)
+	}
+	if fake.calls["GET messages/{id}"] != fetched {
+		t.Errorf("second scan fetched %d more emails, want none", fake.calls["GET messages/{id}"]-fetched)
+	}
+	if !strings.Contains(output, "news@store.example") {
+		t.Errorf("second scan does not list the newsletter:\n%s", output)
+	}
+}
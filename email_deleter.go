@@ -3,16 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"net/mail"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
+	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
 )
@@ -33,61 +37,342 @@ var (
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// Run the command given on the command line, returning the exit code. Exiting
+// is left to main, so that everything deferred here runs first
+func run() (code int) {
+	defer func() {
+		code = partialFailureCode(code)
+	}()
 
-	// Read credentials file
-	data, err := os.ReadFile("credentials.json")
+	// Parse command line flags
+	command, args, err := parseArgs(os.Args[1:])
 	if err != nil {
-		log.Fatalf("Unable to read credentials file: %v\n", err)
+		return failedCode(exitUsage, "Invalid arguments", "err", err)
 	}
+	setupOutput()
+	setupColor()
 
-	// Store access credentials for Google Cloud project in struct
-	var creds Credentials
-	if err := json.Unmarshal(data, &creds); err != nil {
-		log.Fatalf("Unable to parse credentials: %v\n", err)
+	// Cancelled on the first signal, so reads waiting on Gmail stop straight away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchSignals(cancel)
+	if err := setupLogging(); err != nil {
+		return failed("Unable to open log file", "err", err)
 	}
+	defer func() {
+		notifyFinished(runFailure)
+	}()
 
-	// This struct contains the OAuth settings which
-	// will be used to get an authenticated client
-	config := &oauth2.Config{
-		ClientID:     creds.Web.ClientID,
-		ClientSecret: creds.Web.ClientSecret,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://accounts.google.com/o/oauth2/auth",
-			TokenURL: "https://oauth2.googleapis.com/token",
-		},
-		Scopes: []string{
-			gmail.GmailModifyScope,
-			gmail.GmailReadonlyScope,
-		},
-		RedirectURL: "http://localhost:8080/callback", // Must register as authorised redirect URI in Google Cloud project
+	// Commands which do not need to talk to Gmail
+	switch command {
+	case "", "forecast", "trash", "sent", "forwarded", "undo", "heatmap", "show", "spam", "bounces", "mark-read", "delete", "domains", "report", "scan", "attachments", "duplicates", "categories", "inactive", "labels", "export", "tlds":
+	case "state":
+		if err := runStateCommand(args); err != nil {
+			return failed("State command failed", "err", err)
+		}
+		return 0
+	case "diff":
+		if err := runDiffCommand(args); err != nil {
+			return failed("Diff command failed", "err", err)
+		}
+		return 0
+	case "accounts":
+		if err := runAccountsCommand(args); err != nil {
+			return failed("Accounts command failed", "err", err)
+		}
+		return 0
+	case "completion":
+		if err := runCompletionCommand(args); err != nil {
+			return failed("Completion command failed", "err", err)
+		}
+		return 0
+	default:
+		return failedCode(exitUsage, "Unknown command", "command", command)
+	}
+
+	// Use the scopes from the config file if there are any
+	scopes := []string{
+		gmail.GmailModifyScope,
+		gmail.GmailReadonlyScope,
+	}
+	if len(opts.Scopes) > 0 {
+		scopes = opts.Scopes
+	}
+
+	// Deleting emails outright rather than trashing them needs full access to
+	// the mailbox. A token with full access is kept apart from the main token,
+	// so the main token is never reused without the scope
+	if needsFullScope(command, args) && len(opts.Scopes) == 0 {
+		scopes = []string{gmail.MailGoogleComScope}
+		if opts.AccessToken == "" {
+			opts.TokenFile = profileFile("token-full", ".json")
+		}
+	}
+
+	// The storage overview comes from Drive, which needs its own scope
+	if opts.Storage {
+		scopes = append(scopes, drive.DriveMetadataReadonlyScope)
 	}
 
-	// Get an authenticated client
-	client, err := getClient(config)
+	// The scan command never changes the mailbox, so only asks for read access.
+	// Unless the main token already exists, a read-only token is kept apart
+	// from it, so that the main token always has the scopes deletion needs.
+	// Finding large messages with --larger-than offers to delete them, so is excluded
+	if command == "scan" && opts.LargerThan == "" && len(opts.Scopes) == 0 {
+		scopes = []string{gmail.GmailReadonlyScope}
+		if opts.Storage {
+			scopes = append(scopes, drive.DriveMetadataReadonlyScope)
+		}
+		if _, err := os.Stat(opts.TokenFile); err != nil {
+			opts.TokenFile = profileFile("token-readonly", ".json")
+		}
+	}
+
+	// Get an authenticated client. A token provided by another system is used
+	// as it is, otherwise the profile's saved token or the local OAuth flow is used
+	var client *http.Client
+	if opts.AccessToken != "" {
+		client, err = externalClient(opts.AccessToken, scopes)
+	} else {
+		var config *oauth2.Config
+		config, err = oauthConfig(scopes)
+		if err == nil {
+			client, err = getClient(config)
+		}
+	}
 	if err != nil {
-		log.Fatalf("Could not get authenticated client: %v\n", err)
+		return failedCode(exitAuth, "Could not get authenticated client", "err", err)
 	}
 
 	// Create a new Gmail service using the authenticated client
-	srv, err := gmail.NewService(context.Background(), option.WithHTTPClient(client))
+	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		log.Fatalf("Unable to create Gmail service: %v\n", err)
+		return failed("Unable to create Gmail service", "err", err)
+	}
+	defer logAPIMetrics()
+	if opts.Storage {
+		showStorageOverview(ctx, client)
 	}
 
-	// Get sender statistics
-	senderStats, err := getSenderStats(srv)
-	if err != nil {
-		log.Fatalf("Unable to get sender statistics: %v\n", err)
+	// Answers are loaded before any command runs, as every command's prompts
+	// can be answered from them
+	if err := loadAnswers(opts.Answers); err != nil {
+		return failedCode(exitUsage, "Unable to read answers", "err", err)
+	}
+
+	// Commands which do not need a scan of the mailbox
+	switch command {
+	case "trash":
+		if err := runTrashCommand(ctx, srv, args); err != nil {
+			return failed("Trash command failed", "err", err)
+		}
+		return 0
+	case "sent":
+		if err := runSentCommand(ctx, srv); err != nil {
+			return failed("Sent command failed", "err", err)
+		}
+		return 0
+	case "spam":
+		if err := runSpamCommand(ctx, srv, args); err != nil {
+			return failed("Spam command failed", "err", err)
+		}
+		return 0
+	case "bounces":
+		if err := runBouncesCommand(ctx, srv, args); err != nil {
+			return failed("Bounces command failed", "err", err)
+		}
+		return 0
+	case "mark-read":
+		if err := runMarkReadCommand(ctx, srv, args); err != nil {
+			return failed("Mark read command failed", "err", err)
+		}
+		return 0
+	case "delete":
+		if err := runDeleteCommand(ctx, srv, args); err != nil {
+			return failed("Delete command failed", "err", err)
+		}
+		return 0
+	case "undo":
+		if err := runUndoCommand(ctx, srv, args); err != nil {
+			return failed("Undo command failed", "err", err)
+		}
+		return 0
+	case "show":
+		if err := runShowCommand(ctx, srv, args); err != nil {
+			return failed("Show command failed", "err", err)
+		}
+		return 0
+	case "scan":
+		if opts.LargerThan != "" {
+			if err := runLargeMessagesCommand(ctx, srv); err != nil {
+				return failed("Large message scan failed", "err", err)
+			}
+			return 0
+		}
+	}
+
+	// Get sender statistics, either from a saved snapshot or by scanning the mailbox
+	var senderStats []SenderStats
+	if opts.FromSnapshot != "" {
+		snapshot, err := loadSnapshot(opts.FromSnapshot)
+		if err != nil {
+			return failed("Unable to load snapshot", "err", err)
+		}
+		logger.Info("Loaded snapshot", "path", opts.FromSnapshot, "created_at", snapshot.CreatedAt, "senders", len(snapshot.Senders))
+		if snapshot.Partial {
+			logger.Warn("Snapshot is from an interrupted scan, so only covers part of the mailbox")
+		}
+		senderStats = snapshot.Senders
+	} else {
+		// Saved rules are applied before the scan, so the statistics reflect what they did
+		if command == "" {
+			applyRules(ctx, srv)
+		}
+
+		senderStats, err = getSenderStats(ctx, srv)
+		partial := errors.Is(err, errInterrupted)
+		if err != nil && !partial {
+			return failed("Unable to get sender statistics", "err", err)
+		}
+
+		// Save the results so they can be reused without rescanning. An
+		// interrupted scan is saved as a checkpoint of what was scanned, unless
+		// it was stopped before finding anything, when the last snapshot is kept
+		if partial && len(senderStats) == 0 {
+			fmt.Fprintf(display, "Scan interrupted before any senders were found. Kept the last snapshot at %s\n", snapshotPath())
+			return interruptedExitCode
+		}
+		if err := saveSnapshot(snapshotPath(), senderStats, partial); err != nil {
+			logger.Warn("Unable to save snapshot", "err", err)
+		} else {
+			logger.Info("Saved scan results", "path", snapshotPath(), "partial", partial)
+		}
+		if partial {
+			fmt.Fprintf(display, "Scan interrupted. Saved the %d senders found so far to %s\n", len(senderStats), snapshotPath())
+			return interruptedExitCode
+		}
+	}
+
+	// Addresses which obviously belong to one sender are treated as that sender
+	if !opts.KeepAliases {
+		senderStats = mergeAliases(senderStats)
+	}
+
+	// Sort senders in the order they will be prompted about, most emails first by default
+	sortSenders(senderStats, opts.Sort)
+
+	if jsonOutput() {
+		emitJSON(SenderStatsRecord{Type: "sender_stats", Senders: redactStats(senderStats)})
+	}
+
+	// Commands which only report on the scan
+	switch command {
+	case "forecast":
+		printForecast(senderStats, time.Now())
+		return 0
+	case "forwarded":
+		if err := runForwardedCommand(ctx, srv, senderStats); err != nil {
+			return failed("Forwarded command failed", "err", err)
+		}
+		return 0
+	case "heatmap":
+		if err := runHeatmapCommand(senderStats, args); err != nil {
+			return failed("Heatmap command failed", "err", err)
+		}
+		return 0
+	case "scan":
+		printScanSummary(senderStats)
+		return 0
+	case "attachments":
+		printAttachmentReport(senderStats)
+		if err := printAttachmentTypes(ctx, srv, senderStats); err != nil {
+			return failed("Attachment type report failed", "err", err)
+		}
+		return 0
+	case "export":
+		if err := runExportCommand(ctx, srv, senderStats); err != nil {
+			return failed("Export command failed", "err", err)
+		}
+		return 0
+	case "tlds":
+		if err := runTLDsCommand(ctx, srv, senderStats); err != nil {
+			return failed("TLDs command failed", "err", err)
+		}
+		return 0
+	case "labels":
+		runLabelsCommand(ctx, srv, senderStats)
+		return 0
+	case "inactive":
+		if err := runInactiveCommand(ctx, srv, senderStats, args, time.Now()); err != nil {
+			return failed("Inactive command failed", "err", err)
+		}
+		return 0
+	case "categories":
+		if err := runCategoriesCommand(ctx, srv, senderStats); err != nil {
+			return failed("Categories command failed", "err", err)
+		}
+		return 0
+	case "duplicates":
+		if err := runDuplicatesCommand(ctx, srv, senderStats); err != nil {
+			return failed("Duplicates command failed", "err", err)
+		}
+		return 0
+	case "report":
+		if err := runReportCommand(senderStats, time.Now()); err != nil {
+			return failed("Report command failed", "err", err)
+		}
+		return 0
+	case "domains":
+		if err := runDomainsCommand(senderStats, args, time.Now()); err != nil {
+			return failed("Domains command failed", "err", err)
+		}
+		return 0
 	}
 
 	// Process emails, get top senders and prompt user for which ones they would like to delete
-	processEmails(srv, senderStats)
+	remindAboutTrash(ctx, srv)
+	processEmails(ctx, srv, senderStats)
+	printStorageFreed()
+	if interrupted() {
+		return interruptedExitCode
+	}
+	return 0
+}
+
+// Read the profile's credentials file, and return the OAuth settings
+// which will be used to get an authenticated client
+func oauthConfig(scopes []string) (*oauth2.Config, error) {
+	data, err := os.ReadFile(opts.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read credentials file: %w", err)
+	}
+
+	// Store access credentials for Google Cloud project in struct
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("unable to parse credentials: %w", err)
+	}
+
+	return &oauth2.Config{
+		ClientID:     creds.Web.ClientID,
+		ClientSecret: creds.Web.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+			TokenURL: "https://oauth2.googleapis.com/token",
+		},
+		Scopes:      scopes,
+		RedirectURL: "http://localhost:8080/callback", // Must register as authorised redirect URI in Google Cloud project
+	}, nil
 }
 
 // Create HTTP server to handle the OAuth callback (authentication does not work if this is not called)
 func startServer() *http.Server {
-	// Start the server on localhost:8080, as this is an authorised redirect URI in the Google Cloud project
-	srv := &http.Server{Addr: ":8080"}
+	// Start the server on localhost:8080, as this is an authorised redirect URI in the Google Cloud project.
+	// Only listening on localhost also avoids a firewall prompt on Windows
+	srv := &http.Server{Addr: "localhost:8080"}
 
 	// Handles the /callback endpoint
 	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
@@ -109,7 +394,7 @@ func startServer() *http.Server {
 	// Goroutine which runs the server above
 	go func() {
 		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
-			log.Printf("HTTP server error: %v\n", err)
+			logger.Error("HTTP server error", "err", err)
 		}
 	}()
 
@@ -118,8 +403,8 @@ func startServer() *http.Server {
 
 // Get OAuth authenticated client
 func getClient(config *oauth2.Config) (*http.Client, error) {
-	// Try and find the token from token.json
-	tokFile := "token.json"
+	// Try and find the token from the profile's token file
+	tokFile := opts.TokenFile
 	tok, err := tokenFromFile(tokFile)
 
 	// If that didn't work, then get one from the web
@@ -141,19 +426,19 @@ func getTokenFromWeb(config *oauth2.Config) (*oauth2.Token, error) {
 	srv := startServer()
 	defer func() {
 		if err := srv.Shutdown(context.Background()); err != nil {
-			log.Printf("HTTP server shutdown error: %v\n", err)
+			logger.Error("HTTP server shutdown error", "err", err)
 		}
 	}()
 
 	// The user can visit this URL to get the authorisation token
 	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Please visit the following URL to authorize this application:\n%v\n", authURL)
+	fmt.Fprintf(display, "Please visit the following URL to authorize this application:\n%v\n", authURL)
 
 	// Wait for the callback (which calls wg.Done())
 	wg.Wait()
 
 	if authErr != nil {
-		fmt.Printf("Error getting authorisation code: %v\n", authErr)
+		logger.Error("Error getting authorisation code", "err", authErr)
 		return nil, authErr
 	}
 
@@ -189,108 +474,1141 @@ func saveToken(path string, token *oauth2.Token) error {
 // This function gets the emails the user has received, finds the accounts
 // which have sent them the most emails, asks the users if they would like
 // to delete all emails sent from that account, then handles the deletion API calls
-func processEmails(srv *gmail.Service, senderStats []SenderStats) {
-	// Sort implementation for senderStats
-	sort.Slice(senderStats, func(i, j int) bool {
-		return senderStats[i].Count > senderStats[j].Count
-	})
+func processEmails(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) {
+	// The total is used to pick out senders who make up a large part of the mailbox
+	totalEmails := 0
+	for _, sender := range senderStats {
+		totalEmails += sender.Count
+	}
 
-	// Display top senders and prompt for deletion
-	fmt.Printf("\nTop email senders:\n")
-	for i := 0; i < len(senderStats); i++ {
-		sender := senderStats[i]
-		fmt.Printf("%d. %s (%d emails)\n", i+1, sender.Email, sender.Count)
+	// With --newsletters-only, everything which is not a newsletter or mailing list is left out
+	if opts.NewslettersOnly {
+		var newsletters []SenderStats
+		for _, sender := range senderStats {
+			if sender.Newsletter {
+				newsletters = append(newsletters, sender)
+			}
+		}
+		fmt.Fprintf(display, "Only showing the %d senders of newsletters and mailing lists because of --newsletters-only\n", len(newsletters))
+		senderStats = newsletters
+	}
 
-		var response string
-		fmt.Printf("Would you like to delete all emails from %s? (yes/no/quit):\n", sender.Email)
-		fmt.Scanln(&response)
+	// With --automated-only, only no-reply and other automated senders are prompted about
+	if opts.AutomatedOnly {
+		var automated []SenderStats
+		for _, sender := range senderStats {
+			if sender.Automated {
+				automated = append(automated, sender)
+			}
+		}
+		fmt.Fprintf(display, "Only showing the %d automated senders because of --automated-only\n", len(automated))
+		senderStats = automated
+	}
 
-		if strings.ToLower(response) == "yes" {
-			fmt.Printf("Deleting emails from %s...\n", sender.Email)
-			err := deleteEmails(srv, sender.Ids)
-			if err != nil {
-				fmt.Printf("Error deleting emails: %v\n", err)
-			} else {
-				fmt.Printf("Successfully deleted %d emails from %s\n", sender.Count, sender.Email)
+	// With --cc-only, only senders who have never emailed the account directly are prompted about
+	if opts.CCOnly {
+		var copied []SenderStats
+		for _, sender := range senderStats {
+			if isCCOnly(sender) {
+				copied = append(copied, sender)
+			}
+		}
+		fmt.Fprintf(display, "Only showing the %d senders who have only ever copied you in because of --cc-only\n", len(copied))
+		senderStats = copied
+	}
+
+	// With --group-by domain, each domain is prompted about as if it was one sender
+	var members map[string][]SenderStats
+	if opts.GroupBy == "domain" {
+		senderStats, members = groupByDomain(senderStats)
+		sortSenders(senderStats, opts.Sort)
+	}
+	choices := "yes/no/keep/archive/read/label <name>/domain/protect/delete <numbers>//search/rule: .../quit"
+	if members != nil {
+		choices = "yes/no/keep/archive/read/label <name>/senders/domain/protect/delete <numbers>//search/rule: .../quit"
+	}
+
+	// Senders below the minimum count are grouped together and never prompted about
+	senderStats, others, otherSenders := groupSmallSenders(senderStats, opts.MinCount)
+
+	// Apply the --top and --stop-below limits. Senders may not be sorted by
+	// count, so every sender is checked against --stop-below
+	var limited []SenderStats
+	for _, sender := range senderStats {
+		if sender.Count >= opts.StopBelow {
+			limited = append(limited, sender)
+		}
+	}
+	if opts.Top > 0 && len(limited) > opts.Top {
+		limited = limited[:opts.Top]
+	}
+	if len(limited) < len(senderStats) {
+		fmt.Fprintf(display, "Only showing the top %d senders because of --top/--stop-below\n", len(limited))
+		senderStats = limited
+	}
+
+	// Display the ranked list of top senders
+	fmt.Fprintf(display, "\nTop email senders:\n")
+	printSenderList(senderStats, totalEmails, "")
+	if otherSenders > 0 {
+		fmt.Fprintf(display, "(%d senders with fewer than %d emails each are grouped as others: %d emails, not prompted about)\n",
+			otherSenders, opts.MinCount, others.Count)
+	}
+
+	// Senders which have already been answered, or dealt with as part of an earlier answer
+	handled := make(map[string]bool)
+
+	// Search text entered with /search. While set, only matching senders are prompted about
+	filter := ""
+
+	// Prompt for deletion one sender at a time
+	for i := 0; ; i++ {
+		// Once the search results run out, go back to the senders which have not been answered yet
+		if i >= len(senderStats) {
+			if filter == "" {
+				break
 			}
-		} else if strings.ToLower(response) == "no" {
+			fmt.Fprintf(display, "No more senders match %q, continuing with the remaining senders\n", filter)
+			filter = ""
+			i = -1
 			continue
-		} else if strings.ToLower(response) == "quit" {
-			fmt.Printf("Quitting\n")
+		}
+
+		sender := senderStats[i]
+		if interrupted() {
 			break
+		}
+		if handled[sender.Email] || isProtectedSender(sender) || !matchesFilter(sender, filter) {
+			continue
+		}
+
+		counts := fmt.Sprintf("%d emails", sender.Count)
+		if opts.Threads {
+			counts = fmt.Sprintf("%d conversations, %s", len(sender.Threads), counts)
+		}
+		fmt.Fprintf(display, "\n%s\n", colorize(senderColor(sender, totalEmails), fmt.Sprintf("%d. %s (%s, %s unread)", i+1, redactAddress(sender.Email), counts, formatPercent(unreadRatio(sender)))))
+		if kept := describeKept(sender); kept != "" {
+			fmt.Fprintf(display, "%s\n", colorize(colorYellow, "Warning: "+kept+" emails from this sender"))
+		}
+		if len(sender.Aliases) > 1 {
+			fmt.Fprintf(display, "Sends from: %s\n", describeAliases(sender))
+		}
+		if opts.Sort == "score" {
+			fmt.Fprintf(display, "Deletion score: %.2f\n", deletionScore(sender, time.Now()))
+		}
+		if pattern := describePattern(sender); pattern != "" {
+			fmt.Fprintf(display, "Sends %s\n", pattern)
+		}
+		if transactional := describeTransactional(sender); transactional != "" {
+			fmt.Fprintf(display, "Transactional: %s (answer keep to delete everything else)\n", transactional)
+		}
+		if recipients := describeRecipients(sender); recipients != "" {
+			fmt.Fprintf(display, "Addressed: %s\n", recipients)
+		}
+		if isFilteredUnread(sender) {
+			fmt.Fprintf(display, "Filtered out of the inbox and never read: %d of %d emails\n", sender.ArchivedUnread, sender.Count)
+		}
+		if isInactive(sender, time.Now()) {
+			fmt.Fprintf(display, "Inactive: nothing sent since %s\n", formatDate(sender.LastSeen))
+		}
+		if subjects := describeSubjects(sender); subjects != "" && !opts.Redact {
+			fmt.Fprintf(display, "Top subjects: %s\n", subjects)
+			keywords, transactional := describeKeywords(sender)
+			if keywords != "" {
+				fmt.Fprintf(display, "Subject keywords: %s\n", keywords)
+			}
+			if transactional {
+				fmt.Fprintf(display, "%s\n", colorize(colorYellow, "Warning: some subjects look transactional (marked !), consider a subject rule instead of deleting everything"))
+			}
+		}
+		if labels := describeLabels(ctx, srv, sender); labels != "" {
+			fmt.Fprintf(display, "Labels: %s\n", labels)
+		}
+		if sender.ListID != "" {
+			fmt.Fprintf(display, "Mailing list: %s\n", redactListID(sender.ListID))
+		}
+		fmt.Fprintf(display, "Emails per month over the last year: [%s]\n", sparkline(sender.MonthlyCount, time.Now()))
+		response, scripted := scriptedAnswer(sender)
+		ok := true
+		if scripted {
+			fmt.Fprintf(display, "Answering %q from the answers file\n", response)
 		} else {
-			fmt.Printf("Please enter 'yes', 'no' or 'quit'. Retrying current sender.\n")
+			previewSender(ctx, srv, sender)
+			deletable, kept := deletableEmails(sender, time.Now())
+			if len(kept) > 0 {
+				fmt.Fprintf(display, "Deleting them would free about %s, keeping %s", formatSize(deletable.Size), strings.Join(kept, " and "))
+			} else {
+				fmt.Fprintf(display, "Deleting all of them would free about %s", formatSize(sender.Size))
+			}
+			if len(sender.Transactional) > 0 {
+				fmt.Fprintf(display, ", or %s keeping the transactional ones", formatSize(withoutTransactional(deletable).Size))
+			}
+			fmt.Fprintf(display, "\n")
+			fmt.Fprintf(display, "Would you like to delete all emails from %s? (%s):\n", redactAddress(sender.Email), choices)
+			response, ok = readAnswer(opts.DefaultAnswer)
+		}
+		if !ok {
+			fmt.Fprintf(display, "No more input, quitting\n")
+			break
+		}
+		answer := strings.ToLower(response)
+
+		switch {
+		case answer == "yes":
+			handled[sender.Email] = true
+			deleteSender(ctx, srv, sender)
+
+			// Offer to delete similar looking senders too
+			for _, similar := range confirmSimilarSenders(sender, senderStats[i+1:], handled, "delete all emails from") {
+				deleteSender(ctx, srv, similar)
+			}
+		case answer == "keep":
+			// Delete everything except the sender's receipts, invites and other transactional emails
+			if opts.Threads {
+				fmt.Fprintf(display, "keep deletes individual emails, so cannot be used with --threads. Retrying current sender.\n")
+				i--
+				continue
+			}
+			handled[sender.Email] = true
+			if remaining := withoutTransactional(sender); remaining.Count > 0 {
+				fmt.Fprintf(display, "Keeping %d transactional emails\n", sender.Count-remaining.Count)
+				deleteSender(ctx, srv, remaining)
+			} else {
+				fmt.Fprintf(display, "All of the emails from %s are transactional, so none were deleted\n", redactAddress(sender.Email))
+			}
+		case answer == "archive":
+			handled[sender.Email] = true
+			archiveSender(ctx, srv, sender)
+		case answer == "read":
+			handled[sender.Email] = true
+			markSenderRead(ctx, srv, sender)
+		case strings.HasPrefix(answer, "label "):
+			// The label name keeps the case it was typed in
+			name := strings.TrimSpace(response[len("label "):])
+			if name == "" {
+				fmt.Fprintf(display, "Please give a label name, e.g. label Review. Retrying current sender.\n")
+				i--
+				continue
+			}
+			handled[sender.Email] = true
+			labelSender(ctx, srv, sender, name)
+		case answer == "no":
+			handled[sender.Email] = true
+		case answer == "senders" && members != nil:
+			// Drill down into the senders making up this domain, then ask again
+			printDomainMembers(sender.Email, members[sender.Email])
+			i--
+		case answer == "domain":
+			// Skip this sender and every other sender from the same domain
+			_, domain := splitAddress(sender.Email)
+			skipped := 0
+			for _, other := range senderStats {
+				if _, otherDomain := splitAddress(other.Email); otherDomain == domain && !handled[other.Email] {
+					handled[other.Email] = true
+					skipped++
+				}
+			}
+			fmt.Fprintf(display, "Skipping %d senders from %s\n", skipped, domain)
+		case strings.HasPrefix(answer, "/"):
+			// Only prompt about senders matching the search, starting from the top of the list
+			filter = strings.TrimSpace(strings.TrimPrefix(answer, "/"))
+			if filter == "" {
+				fmt.Fprintf(display, "Search cleared\n")
+				i--
+				continue
+			}
+			fmt.Fprintf(display, "Senders matching %q:\n", filter)
+			printSenderList(senderStats, totalEmails, filter)
+			i = -1
+		case answer == "protect":
+			handled[sender.Email] = true
+			// Protect the sender for the rest of this run, along with any similar looking senders
+			opts.Protected = append(opts.Protected, sender.Email)
+			fmt.Fprintf(display, "%s\n", colorize(colorGreen, "Protected "+redactAddress(sender.Email)))
+			for _, similar := range confirmSimilarSenders(sender, senderStats[i+1:], handled, "protect") {
+				opts.Protected = append(opts.Protected, similar.Email)
+				fmt.Fprintf(display, "%s\n", colorize(colorGreen, "Protected "+redactAddress(similar.Email)))
+			}
+		case strings.HasPrefix(answer, "delete "):
+			// Delete several senders from the ranked list at once
+			selected, err := parseSelection(strings.TrimPrefix(answer, "delete "), len(senderStats))
+			if err != nil {
+				fmt.Fprintf(display, "Invalid selection: %v. Retrying current sender.\n", err)
+				i--
+				continue
+			}
+			var plan []SenderStats
+			planCount, planSize := 0, int64(0)
+			for _, n := range selected {
+				selectedSender := senderStats[n-1]
+				if handled[selectedSender.Email] || isProtectedSender(selectedSender) {
+					continue
+				}
+				plan = append(plan, selectedSender)
+				planCount += selectedSender.Count
+				planSize += selectedSender.Size
+			}
+			fmt.Fprintf(display, "Deleting %d emails from %d senders, freeing about %s\n", planCount, len(plan), formatSize(planSize))
+			for _, selectedSender := range plan {
+				handled[selectedSender.Email] = true
+				deleteSender(ctx, srv, selectedSender)
+			}
+
+			// Come back to the current sender unless it was one of those deleted
+			if !handled[sender.Email] {
+				i--
+			}
+		case strings.HasPrefix(answer, "rule:"):
+			// Create a rule for this sender, and apply it straight away
+			rule, err := parseRule(response, sender.Email)
+			if err != nil {
+				fmt.Fprintf(display, "Invalid rule: %v. Retrying current sender.\n", err)
+				i--
+				continue
+			}
+			if err := addRule(rule); err != nil {
+				logger.Error("Unable to save rule", "err", err)
+			} else {
+				fmt.Fprintf(display, "Saved rule: %s\n", rule)
+			}
+			handled[sender.Email] = true
+			applyRule(ctx, srv, rule)
+		case answer == "quit":
+			fmt.Fprintf(display, "Quitting\n")
+			return
+		default:
+			fmt.Fprintf(display, "Please enter one of %s. Retrying current sender.\n", choices)
 			i--
 		}
 	}
 }
 
-func getSenderStats(srv *gmail.Service) ([]SenderStats, error) {
-	senderMap := make(map[string]*SenderStats)
+// Print every sender found by the scan, for the scan command. In JSON output
+// mode the statistics have already been written, so only the summary is shown
+func printScanSummary(senderStats []SenderStats) {
+	totalEmails := 0
+	var totalSize int64
+	positions := make([]int, len(senderStats))
+	for i, sender := range senderStats {
+		totalEmails += sender.Count
+		totalSize += sender.Size
+		positions[i] = i
+	}
+	fmt.Fprintf(display, "\nScanned %d emails (%s) from %d senders\n", totalEmails, formatSize(totalSize), len(senderStats))
+	if !jsonOutput() {
+		printSenderRows(senderStats, positions, totalEmails)
+		printScanCharts(senderStats, time.Now())
+		printFilteredUnread(senderStats)
+	}
+}
 
-	// Fetch the emails using the List method page by page
-	pageToken := ""
+// Sort senders by the given key: count (most emails first), size (most
+// storage first), recent (most recent email first), unread (highest share
+// of unread emails first) or score (safest to delete first). Ties are broken by the number of emails, or the
+// number of conversations with --threads
+func sortSenders(senderStats []SenderStats, key string) {
+	now := time.Now()
+	sort.SliceStable(senderStats, func(i, j int) bool {
+		a, b := senderStats[i], senderStats[j]
+		switch key {
+		case "size":
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		case "recent":
+			if !a.LastSeen.Equal(b.LastSeen) {
+				return a.LastSeen.After(b.LastSeen)
+			}
+		case "unread":
+			if ra, rb := unreadRatio(a), unreadRatio(b); ra != rb {
+				return ra > rb
+			}
+		case "score":
+			if sa, sb := deletionScore(a, now), deletionScore(b, now); sa != sb {
+				return sa > sb
+			}
+		}
+		if opts.Threads && len(a.Threads) != len(b.Threads) {
+			return len(a.Threads) > len(b.Threads)
+		}
+		return a.Count > b.Count
+	})
+}
+
+// Returns the share of the sender's emails which have not been read
+func unreadRatio(sender SenderStats) float64 {
+	if sender.Count == 0 {
+		return 0
+	}
+	return float64(sender.Unread) / float64(sender.Count)
+}
+
+// Format a fraction as a whole percentage, e.g. 0.25 as "25%"
+func formatPercent(fraction float64) string {
+	return fmt.Sprintf("%.0f%%", fraction*100)
+}
+
+// Returns true if the sender's address contains the search text, or the search is empty
+func matchesFilter(sender SenderStats, filter string) bool {
+	return strings.Contains(strings.ToLower(sender.Email), strings.ToLower(filter))
+}
+
+// Print the ranked list of senders matching the search text as a table,
+// numbered by their position in the full list. Long lists are shown
+// --page-size senders at a time, with next/prev navigation between pages
+func printSenderList(senderStats []SenderStats, totalEmails int, filter string) {
+	var matching []int
+	for i, sender := range senderStats {
+		if matchesFilter(sender, filter) {
+			matching = append(matching, i)
+		}
+	}
+
+	if opts.PageSize <= 0 || len(matching) <= opts.PageSize {
+		printSenderRows(senderStats, matching, totalEmails)
+		return
+	}
+
+	pages := (len(matching) + opts.PageSize - 1) / opts.PageSize
+	page := 0
 	for {
-		req := srv.Users.Messages.List("me")
-		if pageToken != "" {
-			req.PageToken(pageToken)
+		start := page * opts.PageSize
+		printSenderRows(senderStats, matching[start:min(start+opts.PageSize, len(matching))], totalEmails)
+		fmt.Fprintf(display, "Page %d of %d (n)ext, (p)rev, or press enter to start prompting:\n", page+1, pages)
+		response, ok := readPrompt("page")
+		if !ok {
+			return
+		}
+		switch strings.ToLower(response) {
+		case "n", "next":
+			page = min(page+1, pages-1)
+		case "p", "prev":
+			page = max(page-1, 0)
+		case "":
+			return
+		default:
+			fmt.Fprintf(display, "Please enter 'n', 'p', or nothing to start prompting.\n")
 		}
+	}
+}
 
-		// Perform the request and handle errors
-		r, err := req.Do()
-		if err != nil {
+// Print the senders at the given positions in the ranked list as a table
+func printSenderRows(senderStats []SenderStats, positions []int, totalEmails int) {
+	table := newTable("#", "Sender", "Emails", "Unread", "To me", "First seen", "Last seen", "Notes").AlignRight(0, 2, 3, 4)
+	for _, i := range positions {
+		sender := senderStats[i]
+		var notes []string
+		if kept := describeKept(sender); kept != "" {
+			notes = append(notes, "! "+kept)
+		}
+		switch {
+		case sender.ListID != "":
+			notes = append(notes, "mailing list")
+		case sender.Newsletter:
+			notes = append(notes, "newsletter")
+		}
+		if len(sender.Aliases) > 1 {
+			notes = append(notes, fmt.Sprintf("%d addresses", len(sender.Aliases)))
+		}
+		if isInactive(sender, time.Now()) {
+			notes = append(notes, "inactive")
+		}
+		if isFilteredUnread(sender) {
+			notes = append(notes, "filtered, never read")
+		}
+		if len(sender.Transactional) > 0 {
+			notes = append(notes, "transactional")
+		}
+		if sender.Automated {
+			notes = append(notes, "automated")
+		}
+		if sender.Bounces > 0 {
+			notes = append(notes, fmt.Sprintf("%d bounces", sender.Bounces))
+		}
+		if n := sender.Labels["SPAM"]; n > 0 {
+			notes = append(notes, fmt.Sprintf("%d spam", n))
+		}
+		if len(sender.ForwardedFrom) > 0 {
+			notes = append(notes, "forwarded")
+		}
+		if sender.RepliesChecked && sender.Replies == 0 {
+			notes = append(notes, "never replied")
+		}
+		if isProtectedSender(sender) {
+			notes = append(notes, "protected")
+		}
+		table.AddColoredRow(senderColor(sender, totalEmails), strconv.Itoa(i+1), redactAddress(sender.Email), strconv.Itoa(sender.Count),
+			formatPercent(unreadRatio(sender)), describeDirect(sender), formatDate(sender.FirstSeen), formatDate(sender.LastSeen), strings.Join(notes, ", "))
+	}
+	table.Render(display)
+}
+
+// Archive all emails from the given sender, taking them out of the inbox
+// without deleting them
+func archiveSender(ctx context.Context, srv *gmail.Service, sender SenderStats) {
+	inbox := sender.Labels["INBOX"]
+	if inbox == 0 {
+		fmt.Fprintf(display, "None of the emails from %s are in the inbox\n", redactAddress(sender.Email))
+		return
+	}
+	logger.Info("Archiving emails", "sender", sender.Email, "count", inbox)
+	if err := modifyEmails(ctx, srv, sender.Email, sender.Ids, nil, []string{"INBOX"}, "archive"); err != nil {
+		logger.Error("Error archiving emails", "sender", sender.Email, "err", err)
+		return
+	}
+	fmt.Fprintf(display, "%s\n", colorize(colorGreen, fmt.Sprintf("Archived %d emails from %s", inbox, redactAddress(sender.Email))))
+}
+
+// Move all emails from the given sender under the named label, creating it if
+// needed, and take them out of the inbox. This stages mail to be deleted later
+// without deleting anything yet
+func labelSender(ctx context.Context, srv *gmail.Service, sender SenderStats, name string) {
+	id, err := labelID(ctx, srv, name, true)
+	if err != nil {
+		logger.Error("Unable to find label", "label", name, "err", err)
+		return
+	}
+	logger.Info("Labelling emails", "sender", sender.Email, "count", sender.Count, "label", name)
+	if err := modifyEmails(ctx, srv, sender.Email, sender.Ids, []string{id}, []string{"INBOX"}, "label"); err != nil {
+		logger.Error("Error labelling emails", "sender", sender.Email, "err", err)
+		return
+	}
+	fmt.Fprintf(display, "%s\n", colorize(colorGreen, fmt.Sprintf("Moved %d emails from %s to %s", sender.Count, redactAddress(sender.Email), name)))
+}
+
+// Move one email to the Trash, checking that it arrived there
+func trashMessage(ctx context.Context, srv *gmail.Service, id string) error {
+	email, err := callWriteAPI(ctx, "messages.trash", srv.Users.Messages.Trash("me", id))
+	if err != nil {
+		return fmt.Errorf("failed to delete message %s: %w", id, err)
+	}
+	if !slices.Contains(email.LabelIds, "TRASH") {
+		return fmt.Errorf("message %s was not moved to trash successfully", id)
+	}
+	return nil
+}
+
+// Move all emails from the given sender to the Trash, reporting the outcome
+func deleteSender(ctx context.Context, srv *gmail.Service, sender SenderStats) {
+	// Emails the deletion options protect, such as recent ones with --older-than, are left alone
+	sender, kept := deletableEmails(sender, time.Now())
+	if len(kept) > 0 {
+		fmt.Fprintf(display, "Keeping %s\n", strings.Join(kept, ", "))
+	}
+	if sender.Count == 0 {
+		fmt.Fprintf(display, "None of the emails from %s can be deleted, so none were\n", redactAddress(sender.Email))
+		return
+	}
+	logger.Info("Deleting emails", "sender", sender.Email, "count", sender.Count)
+	if jsonOutput() {
+		emitJSON(DeletionPlanRecord{Type: "deletion_plan", Sender: redactAddress(sender.Email), Count: sender.Count, Ids: sender.Ids})
+	}
+	var result DeletionResult
+	var err error
+	if opts.Threads {
+		result, err = deleteThreads(ctx, srv, sender.Email, sender.Threads)
+	} else {
+		result, err = deleteEmails(ctx, srv, sender.Email, sender.Ids)
+	}
+
+	// Emails kept back while deleting, such as starred ones, are not counted,
+	// so only the storage of the emails actually deleted counts as freed
+	deleted := fmt.Sprintf("%d emails", result.Deleted)
+	if opts.Threads {
+		deleted = fmt.Sprintf("%d conversations", result.Deleted)
+		freedBytes += sender.Size * int64(result.Deleted) / int64(max(len(sender.Threads), 1))
+	} else {
+		freedBytes += emailsSize(sender, result.Ids)
+	}
+	if jsonOutput() {
+		emitJSON(DeletionResultRecord{
+			Type:    "deletion_result",
+			Sender:  redactAddress(sender.Email),
+			Deleted: result.Deleted,
+			Failed:  len(result.Errors),
+			Errors:  result.Errors,
+		})
+	}
+	if err != nil {
+		logger.Error("Error deleting emails", "sender", sender.Email, "err", err)
+	} else {
+		fmt.Fprintf(display, "%s\n", colorize(colorRed, fmt.Sprintf("Successfully deleted %s from %s", deleted, redactAddress(sender.Email))))
+	}
+}
+
+// Number of the heaviest senders from the last scan which are scanned first
+const warmStartCount = 20
+
+// If the run is interrupted, the senders found so far are returned along with errInterrupted
+func getSenderStats(ctx context.Context, srv *gmail.Service) ([]SenderStats, error) {
+	defer startWork()()
+	senderMap := make(map[string]*SenderStats)
+
+	// The profile's message total is used to estimate how long the scan will take
+	var total int64
+	profile, err := callAPI(ctx, "users.getProfile", srv.Users.GetProfile("me"))
+	if err != nil {
+		logger.Warn("Could not get mailbox profile, progress will have no ETA", "err", err)
+	} else {
+		total = profile.MessagesTotal
+		accountEmail = profile.EmailAddress
+	}
+	progress := newProgress("Scanning", total)
+	query, includeSpamTrash := scanQuery()
+	logger.Debug("Scanning mailbox", "query", query, "include_spam_trash", includeSpamTrash)
+
+	// Senders who sent the most email last time are scanned first with targeted
+	// queries, so the most useful part of the results arrives early, then the
+	// rest of the mailbox is scanned. Emails seen by an earlier query are skipped
+	var queries []string
+	for _, email := range warmStartSenders() {
+		queries = append(queries, strings.TrimSpace(query+" from:"+email))
+	}
+	queries = append(queries, query)
+	seen := make(map[string]bool)
+
+	// Size of every email scanned, used to total up the size of emails with attachments
+	sizes := make(map[string]int64)
+
+	// The first copy of every distinct email, used to find duplicates
+	firstCopies := make(map[string]string)
+
+	// The sender who started each conversation, used to count conversations
+	threadStarts := make(map[string]threadStart)
+
+	// When each sender's emails arrived, used to work out their sending pattern
+	arrivals := make(map[string][]time.Time)
+
+	// Emails fetched by earlier scans are read from the cache instead of fetched again
+	cache := loadCache()
+	cached := 0
+	cacheQuery := fmt.Sprintf("%s include_spam_trash=%t", query, includeSpamTrash)
+
+	// Add an email to the statistics, returning its sender
+	process := func(message *gmail.Message) string {
+		email := addMessage(senderMap, message)
+		if email != "" {
+			recordThread(threadStarts, message, email)
+			if received := messageTime(message); !received.IsZero() {
+				arrivals[email] = append(arrivals[email], received)
+			}
+
+			// Later copies of an email already seen are noted as duplicates
+			key := duplicateKey(message, email)
+			if _, exists := firstCopies[key]; exists {
+				senderMap[email].Duplicates = append(senderMap[email].Duplicates, message.Id)
+			} else {
+				firstCopies[key] = message.Id
+			}
+		}
+		sizes[message.Id] = message.SizeEstimate
+		return email
+	}
+
+	// After a complete scan, only the changes made to the mailbox since are
+	// needed, and the statistics are rebuilt from the cache. Otherwise the
+	// history still brings the labels of cached emails up to date, and without
+	// it they may have been starred, read or moved since, so are fetched again
+	incremental := false
+	if cache.HistoryID != 0 {
+		err := applyHistory(ctx, srv, cache)
+		switch {
+		case err == nil:
+			incremental = cache.Query == cacheQuery
+		case errors.Is(err, errInterrupted):
+			// The history already applied is kept, and applying it again next time does no harm
+			if err := saveCache(cache); err != nil {
+				logger.Warn("Unable to save message cache", "err", err)
+			}
 			return nil, err
+		default:
+			logger.Info("Could not update scan from mailbox history, scanning the whole mailbox", "err", err)
+			cache.Messages = make(map[string]*gmail.Message)
+		}
+	} else if len(cache.Messages) > 0 {
+		logger.Debug("Message cache has no mailbox history, fetching every email")
+		cache.Messages = make(map[string]*gmail.Message)
+	}
+	if incremental {
+		queries = nil
+		for _, message := range cachedScanMessages(cache) {
+			if interrupted() {
+				break
+			}
+			seen[message.Id] = true
+			cached++
+			progress.Add(1)
+			process(message)
+		}
+	} else {
+		// Changes made during the scan are applied again next time, which does no harm
+		if profile != nil {
+			cache.HistoryID = profile.HistoryId
 		}
+	}
 
-		// Process each email in this "page"
-		for _, msg := range r.Messages {
-			message, err := srv.Users.Messages.Get("me", msg.Id).Format("metadata").Do()
+	// Fetch the emails using the List method page by page
+scan:
+	for _, q := range queries {
+		pageToken := ""
+		for {
+			req := srv.Users.Messages.List("me").Q(q).IncludeSpamTrash(includeSpamTrash)
+			if pageToken != "" {
+				req.PageToken(pageToken)
+			}
+
+			// Perform the request and handle errors
+			r, err := callAPI(ctx, "messages.list", req)
+			if errors.Is(err, errInterrupted) {
+				break scan
+			}
 			if err != nil {
-				fmt.Printf("Could not get metadata for email ID %s, continuing\n", msg.Id)
-				continue
+				return nil, err
 			}
+			progress.Page()
+			logger.Debug("Fetched page of emails", "query", q, "count", len(r.Messages), "next_page", r.NextPageToken)
+
+			// Senders seen in this page, whose updated statistics are streamed once the page is done
+			updated := make(map[string]bool)
 
-			// Use the From header to get the sender, and increment the
-			// count of the number of emails they have sent
-			for _, header := range message.Payload.Headers {
-				if header.Name == "From" {
-					email := extractEmail(header.Value)
-					if stats, exists := senderMap[email]; exists {
-						stats.Count++
-						stats.Ids = append(stats.Ids, msg.Id)
-					} else {
-						senderMap[email] = &SenderStats{
-							Email: email,
-							Count: 1,
-							Ids:   []string{msg.Id},
-						}
+			// Process each email in this "page"
+			for _, msg := range r.Messages {
+				if interrupted() {
+					break scan
+				}
+				if seen[msg.Id] {
+					continue
+				}
+				seen[msg.Id] = true
+
+				message, ok := cache.Messages[msg.Id]
+				if ok {
+					cached++
+				} else {
+					message, err = callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", msg.Id).Format("metadata"))
+					if errors.Is(err, errInterrupted) {
+						break scan
+					}
+					if err != nil {
+						progress.Add(1)
+						logger.Warn("Could not get email metadata, continuing", "id", msg.Id, "err", err)
+						continue
 					}
-					break
+					cacheMessage(cache, message)
 				}
+				progress.Add(1)
+				if email := process(message); email != "" {
+					updated[email] = true
+				}
+			}
+			for email := range updated {
+				streamSender(*senderMap[email], false)
 			}
+
+			// Check if there are more "pages" of emails
+			if r.NextPageToken == "" {
+				break
+			}
+			pageToken = r.NextPageToken
 		}
+	}
+	progress.Finish()
+	assignThreads(senderMap, threadStarts)
+	for email, times := range arrivals {
+		senderMap[email].Period, senderMap[email].Regularity = sendingPattern(times)
+	}
 
-		// Check if there are more "pages" of emails
-		if r.NextPageToken == "" {
-			break
+	// Emails missing from a complete scan have been deleted, so are dropped
+	// from the cache. An incomplete scan cannot be brought up to date from the
+	// history, as the emails it did not reach would never be fetched, but the
+	// history is still kept to refresh the labels of the emails it did reach
+	if interrupted() {
+		cache.Query = ""
+	} else {
+		if !incremental {
+			pruneCache(cache, seen)
+		}
+		cache.Query = cacheQuery
+	}
+	if err := saveCache(cache); err != nil {
+		logger.Warn("Unable to save message cache", "err", err)
+	} else {
+		logger.Debug("Saved message cache", "path", cachePath(), "emails", len(cache.Messages), "read_from_cache", cached)
+	}
+
+	// Gmail knows which emails have attachments, so ask it rather than fetching every email in full
+	if !interrupted() {
+		if err := countAttachments(ctx, srv, senderMap, sizes, query); err != nil {
+			logger.Warn("Could not find emails with attachments", "err", err)
+		}
+	}
+	if !interrupted() && opts.CheckReplies {
+		if err := countReplies(ctx, srv, senderMap); err != nil && !errors.Is(err, errInterrupted) {
+			logger.Warn("Could not check which senders have been replied to", "err", err)
 		}
-		pageToken = r.NextPageToken
 	}
 
 	// Return sender stats as slice
 	var stats []SenderStats
 	for _, v := range senderMap {
 		stats = append(stats, *v)
+		streamSender(*v, !interrupted())
 	}
 
+	if interrupted() {
+		return stats, errInterrupted
+	}
 	return stats, nil
 }
 
-// Stores the email IDs, and number of emails from a particular sender
+// Add an email to the statistics of the sender in its From header, and
+// return the sender. Emails without a From header are ignored
+func addMessage(senderMap map[string]*SenderStats, message *gmail.Message) string {
+	// Use the From header to get the sender, and increment the
+	// count of the number of emails they have sent
+	headers := messageHeaders(message)
+	from, ok := headers["from"]
+	if !ok {
+		return ""
+	}
+	email := extractEmail(from)
+	stats, exists := senderMap[email]
+	if !exists {
+		stats = &SenderStats{
+			Email:        email,
+			MonthlyBytes: make(map[string]int64),
+			MonthlyCount: make(map[string]int),
+		}
+		senderMap[email] = stats
+	}
+	if stats.DisplayName == "" {
+		if address, err := mail.ParseAddress(from); err == nil {
+			stats.DisplayName = address.Name
+		}
+	}
+	stats.Count++
+	stats.Ids = append(stats.Ids, message.Id)
+	stats.Size += message.SizeEstimate
+	if stats.MessageSizes == nil {
+		stats.MessageSizes = make(map[string]int64)
+	}
+	stats.MessageSizes[message.Id] = message.SizeEstimate
+
+	// Bucket the size by the month the email was received in, and
+	// the email by the day of the week and hour it arrived. Emails with
+	// no usable date are left out of the age based statistics
+	received := messageTime(message)
+	if !received.IsZero() {
+		month := received.Format("2006-01")
+		stats.MonthlyBytes[month] += message.SizeEstimate
+		stats.MonthlyCount[month]++
+		stats.Heatmap[received.Weekday()][received.Hour()]++
+		if stats.MessageTimes == nil {
+			stats.MessageTimes = make(map[string]int64)
+		}
+		stats.MessageTimes[message.Id] = received.Unix()
+		if stats.FirstSeen.IsZero() || received.Before(stats.FirstSeen) {
+			stats.FirstSeen = received
+		}
+		if received.After(stats.LastSeen) {
+			stats.LastSeen = received
+		}
+	}
+	if slices.Contains(message.LabelIds, "UNREAD") {
+		stats.Unread++
+		if !slices.Contains(message.LabelIds, "INBOX") {
+			stats.ArchivedUnread++
+		}
+	}
+	if stats.Subjects == nil {
+		stats.Subjects = make(map[string]int)
+	}
+	stats.Subjects[normaliseSubject(headers["subject"])]++
+	if isAutomated(email, headers) {
+		stats.Automated = true
+	}
+	if isBounce(email, headers) {
+		stats.Bounces++
+	}
+	switch recipientKind(headers) {
+	case "direct":
+		stats.Direct++
+	case "cc":
+		stats.CC++
+	}
+	if kind := classifyMessage(headers); kind != "" {
+		if stats.Transactional == nil {
+			stats.Transactional = make(map[string][]string)
+		}
+		stats.Transactional[kind] = append(stats.Transactional[kind], message.Id)
+	}
+	for _, label := range message.LabelIds {
+		if stats.Labels == nil {
+			stats.Labels = make(map[string]int)
+		}
+		stats.Labels[label]++
+		if !received.IsZero() {
+			if stats.LabelAges == nil {
+				stats.LabelAges = make(map[string][]int)
+			}
+			if stats.LabelAges[label] == nil {
+				stats.LabelAges[label] = make([]int, len(retentionBuckets))
+			}
+			stats.LabelAges[label][retentionBucket(received, time.Now())]++
+		}
+
+		// Category tabs also have their storage totalled, for the categories command
+		if strings.HasPrefix(label, "CATEGORY_") {
+			if stats.CategorySizes == nil {
+				stats.CategorySizes = make(map[string]int64)
+			}
+			stats.CategorySizes[label] += message.SizeEstimate
+		}
+	}
+
+	// Newsletters and mailing lists include an unsubscribe header, a list
+	// identifier, or both. The identifier is kept to show which list it is
+	if headers["list-unsubscribe"] != "" || headers["list-id"] != "" {
+		stats.Newsletter = true
+	}
+	if listID := headers["list-id"]; listID != "" {
+		stats.ListID = listID
+	}
+
+	// Note emails which were auto-forwarded from another account
+	if account := forwardedFrom(message); account != "" {
+		if stats.ForwardedFrom == nil {
+			stats.ForwardedFrom = make(map[string]int)
+		}
+		stats.ForwardedFrom[account]++
+	}
+	return email
+}
+
+// Returns the senders who sent the most email according to the last saved
+// scan, which are scanned first. There are none if nothing has been saved yet
+func warmStartSenders() []string {
+	snapshot, err := loadSnapshot(snapshotPath())
+	if err != nil {
+		return nil
+	}
+	senders := snapshot.Senders
+	sort.Slice(senders, func(i, j int) bool {
+		return senders[i].Count > senders[j].Count
+	})
+
+	var emails []string
+	for _, sender := range senders[:min(len(senders), warmStartCount)] {
+		emails = append(emails, sender.Email)
+	}
+	if len(emails) > 0 {
+		logger.Info("Scanning the heaviest senders from the last scan first", "senders", len(emails), "snapshot", snapshotPath())
+	}
+	return emails
+}
+
+// Returns the Gmail search query which leaves the excluded system labels out
+// of the scan, and whether spam and trash need to be listed at all
+func scanQuery() (string, bool) {
+	included := make(map[string]bool)
+	for _, label := range opts.IncludeLabels {
+		included[strings.ToLower(label)] = true
+	}
+
+	var terms []string
+	for _, label := range scanExcludedLabels {
+		if !included[label] {
+			terms = append(terms, "-in:"+label)
+		}
+	}
+	return strings.Join(terms, " "), included["spam"] || included["trash"]
+}
+
+// Returns the IDs of every email matching the given Gmail search query
+func listMessageIds(ctx context.Context, srv *gmail.Service, query string) ([]string, error) {
+	var ids []string
+	pageToken := ""
+	for {
+		req := srv.Users.Messages.List("me").Q(query)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		r, err := callAPI(ctx, "messages.list", req)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range r.Messages {
+			ids = append(ids, msg.Id)
+		}
+		if r.NextPageToken == "" {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	return ids, nil
+}
+
+// Stores the email IDs, number of emails, and storage used by the emails from a
+// particular sender. Storage is also broken down by month (YYYY-MM)
 type SenderStats struct {
-	Email string
-	Count int
-	Ids   []string
+	Email        string           `json:"email"`
+	Count        int              `json:"count"`
+	Ids          []string         `json:"ids"`
+	Size         int64            `json:"size"`
+	MonthlyBytes map[string]int64 `json:"monthly_bytes"`
+	MonthlyCount map[string]int   `json:"monthly_count"`
+	Newsletter   bool             `json:"newsletter"`
+	ListID       string           `json:"list_id,omitempty"`
+
+	// Number of emails forwarded from each of the user's other accounts
+	ForwardedFrom map[string]int `json:"forwarded_from,omitempty"`
+
+	// Number of emails which have not been read
+	Unread int `json:"unread"`
+
+	// Number of emails sent straight to the account, and which only copied it in
+	Direct int `json:"direct"`
+	CC     int `json:"cc"`
+
+	// Number of unread emails which are not in the inbox, i.e. were filtered away and never opened
+	ArchivedUnread int `json:"archived_unread"`
+
+	// Number of emails the user has sent to the sender, if --check-replies was given
+	Replies        int  `json:"replies"`
+	RepliesChecked bool `json:"replies_checked"`
+
+	// IDs of the conversations the sender started
+	Threads []string `json:"threads,omitempty"`
+
+	// IDs of emails which are copies of another of the sender's emails
+	Duplicates []string `json:"duplicates,omitempty"`
+
+	// Number of emails carrying each label, keyed by label ID
+	Labels map[string]int `json:"labels,omitempty"`
+
+	// Name shown alongside the sender's address, from their From header
+	DisplayName string `json:"display_name,omitempty"`
+
+	// Number of emails from the sender in each profile, for the accounts command
+	Accounts map[string]int `json:"accounts,omitempty"`
+
+	// Number of emails from each address, for a sender merged from several addresses
+	Aliases map[string]int `json:"aliases,omitempty"`
+
+	// Number of emails with each subject line, with numbers and reply prefixes removed
+	Subjects map[string]int `json:"subjects,omitempty"`
+
+	// The usual interval between the sender's emails, and the share of
+	// intervals close to it. A regularity near 1 means they send on a schedule
+	Period     time.Duration `json:"period,omitempty"`
+	Regularity float64       `json:"regularity,omitempty"`
+
+	// Whether the sender looks automated, such as a no-reply address
+	Automated bool `json:"automated"`
+
+	// Number of the sender's emails which are bounces or delivery failure notices
+	Bounces int `json:"bounces,omitempty"`
+
+	// IDs of the sender's transactional emails, such as receipts, keyed by their kind
+	Transactional map[string][]string `json:"transactional,omitempty"`
+
+	// Number of emails with each label in each retention report age bucket, keyed by label ID
+	LabelAges map[string][]int `json:"label_ages,omitempty"`
+
+	// Storage used by the sender's emails in each category tab, keyed by label ID
+	CategorySizes map[string]int64 `json:"category_sizes,omitempty"`
+
+	// Number of emails with attachments, and their total size
+	Attachments    int   `json:"attachments"`
+	AttachmentSize int64 `json:"attachment_size"`
+
+	// Size of each of the sender's emails, keyed by ID
+	MessageSizes map[string]int64 `json:"message_sizes,omitempty"`
+
+	// When each of the sender's emails was received, as a Unix time, keyed by ID
+	MessageTimes map[string]int64 `json:"message_times,omitempty"`
+
+	// When the oldest and most recent emails from the sender were received
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// Number of emails received in each hour of each day of the week
+	// (local time), indexed by time.Weekday then hour
+	Heatmap [7][24]int `json:"heatmap"`
+}
+
+// Stores the outcome of deleting a batch of emails
+type DeletionResult struct {
+	Deleted int
+	Errors  []string
+
+	// IDs of the emails which were deleted
+	Ids []string
+}
+
+// Split off the senders who have sent fewer than minCount emails, and
+// aggregate them into a single "others" bucket. Returns the remaining
+// senders, the bucket, and how many senders went into the bucket
+func groupSmallSenders(senderStats []SenderStats, minCount int) ([]SenderStats, SenderStats, int) {
+	others := SenderStats{Email: "others"}
+	if minCount <= 0 {
+		return senderStats, others, 0
+	}
+
+	var kept []SenderStats
+	grouped := 0
+	for _, sender := range senderStats {
+		if sender.Count >= minCount {
+			kept = append(kept, sender)
+			continue
+		}
+		others.Count += sender.Count
+		others.Size += sender.Size
+		others.Ids = append(others.Ids, sender.Ids...)
+		grouped++
+	}
+	return kept, others, grouped
+}
+
+// Returns the headers of an email keyed by their lower case name. Where a
+// header appears more than once, the first value is kept
+func messageHeaders(message *gmail.Message) map[string]string {
+	headers := make(map[string]string)
+	if message.Payload == nil {
+		return headers
+	}
+	for _, header := range message.Payload.Headers {
+		name := strings.ToLower(header.Name)
+		if _, exists := headers[name]; !exists {
+			headers[name] = header.Value
+		}
+	}
+	return headers
+}
+
+// Gets every email address from an address list header such as To or Cc
+func extractAddresses(header string) []string {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+
+	addresses, err := mail.ParseAddressList(header)
+	if err != nil {
+		// Fall back to splitting on commas for headers the parser rejects
+		var emails []string
+		for _, part := range strings.Split(header, ",") {
+			if email := extractEmail(strings.TrimSpace(part)); email != "" {
+				emails = append(emails, email)
+			}
+		}
+		return emails
+	}
+
+	emails := make([]string, len(addresses))
+	for i, address := range addresses {
+		emails[i] = address.Address
+	}
+	return emails
 }
 
 // Gets email address from a From email header
@@ -305,56 +1623,116 @@ func extractEmail(from string) string {
 	return from
 }
 
-// Moves the emails with the passed IDs to the Trash
-func deleteEmails(srv *gmail.Service, ids []string) error {
+// Moves the emails with the passed IDs, which were sent by the given sender,
+// to the Trash, or deletes them permanently with --permanent. Deleted emails
+// are recorded in the journal
+func deleteEmails(ctx context.Context, srv *gmail.Service, sender string, ids []string) (DeletionResult, error) {
+	return removeEmails(ctx, srv, sender, ids, opts.Permanent)
+}
+
+// Moves the emails with the passed IDs to the Trash, or deletes them
+// permanently if permanent is set, recording them in the journal
+func removeEmails(ctx context.Context, srv *gmail.Service, sender string, ids []string, permanent bool) (DeletionResult, error) {
+	defer startWork()()
 	var deleteErrors []string
+	var trashed []string
 	successCount := 0
+	// With --permanent the emails skip the Trash, so are journalled as deleted and cannot be undone
+	action, result := "trash", "trashed"
+	if permanent {
+		if !confirmPermanent() {
+			return DeletionResult{}, fmt.Errorf("permanent deletion was not confirmed")
+		}
+		action, result = "delete", "deleted"
+	}
 
-	// Loop through given emails
-	for _, id := range ids {
+	// Starred and important emails, and those --keep-attachments and --exclude-query cover, are left alone however they were chosen
+	ids, err := withoutKept(ctx, srv, ids, false)
+	if err != nil {
+		return DeletionResult{}, err
+	}
+	defer func() {
+		appendJournal(action, sender, trashed)
+	}()
 
-		// Try and move email to trash
-		email, err := srv.Users.Messages.Trash("me", id).Do()
-		if err != nil {
-			deleteErrors = append(deleteErrors, fmt.Sprintf("failed to delete message %s: %v", id, err))
-			continue
+	// Delete the emails in batches, showing the throughput and ETA as they are deleted
+	progress := newProgress("Deleting", int64(len(ids)))
+	for start := 0; start < len(ids); start += batchSize {
+		if interrupted() {
+			break
 		}
+		batch := ids[start:min(start+batchSize, len(ids))]
 
-		// Check that the email is in trash
-		isInTrash := false
-		for _, label := range email.LabelIds {
-			if label == "TRASH" {
-				isInTrash = true
-				break
+		// The subject cannot be read once the email is trashed, so is fetched first for the deletion log
+		subjects := make(map[string]string)
+		if opts.DeletionLog != "" {
+			for _, id := range batch {
+				subjects[id] = messageSubject(ctx, srv, id)
 			}
 		}
 
-		if !isInTrash {
-			deleteErrors = append(deleteErrors, fmt.Sprintf("message %s was not moved to trash successfully", id))
+		var err error
+		if permanent {
+			req := &gmail.BatchDeleteMessagesRequest{Ids: batch}
+			_, err = callWriteAPI(ctx, "messages.batchDelete", noResult(srv.Users.Messages.BatchDelete("me", req)))
 		} else {
-			successCount++
-			// Print progress every 10 emails
-			if successCount%10 == 0 {
-				fmt.Printf("Successfully deleted %d emails...\n", successCount)
+			req := &gmail.BatchModifyMessagesRequest{Ids: batch, AddLabelIds: []string{"TRASH"}}
+			_, err = callWriteAPI(ctx, "messages.batchModify", noResult(srv.Users.Messages.BatchModify("me", req)))
+		}
+		if err == nil {
+			progress.Add(len(batch))
+			successCount += len(batch)
+			trashed = append(trashed, batch...)
+			logger.Debug("Deleted batch of emails", "count", len(batch), "action", action)
+			for _, id := range batch {
+				logDeletion(id, sender, subjects[id], result)
 			}
+			continue
 		}
+		logger.Warn("Batch deletion failed, deleting the emails one at a time", "count", len(batch), "err", err)
 
-		// Delay to avoid rate limits (TODO: is there a better way to do this?)
-		time.Sleep(100 * time.Millisecond)
+		// If the batch fails, each email is deleted on its own so one bad email does not stop the rest
+		for _, id := range batch {
+			if interrupted() {
+				break
+			}
+			var err error
+			if permanent {
+				err = deleteMessage(ctx, srv, id)
+			} else {
+				err = trashMessage(ctx, srv, id)
+			}
+			progress.Add(1)
+			if err != nil {
+				deleteErrors = append(deleteErrors, err.Error())
+				logDeletion(id, sender, subjects[id], "failed: "+err.Error())
+				continue
+			}
+			successCount++
+			trashed = append(trashed, id)
+			logger.Debug("Deleted email", "id", id, "action", action)
+			logDeletion(id, sender, subjects[id], result)
+		}
 	}
+	progress.Finish()
+	if interrupted() {
+		fmt.Fprintf(display, "Deletion interrupted, %d emails were not processed\n", len(ids)-successCount-len(deleteErrors))
+	}
+
+	failedMessages += len(deleteErrors)
 
 	// Print final summary
-	fmt.Printf("\nDeletion Summary:\n")
-	fmt.Printf("Successfully deleted: %d emails\n", successCount)
+	fmt.Fprintf(display, "\nDeletion Summary:\n")
+	fmt.Fprintf(display, "Successfully deleted: %d emails\n", successCount)
 
 	if len(deleteErrors) > 0 {
-		fmt.Printf("Failed to delete: %d emails\n", len(deleteErrors))
-		fmt.Printf("Error details:\n")
+		fmt.Fprintf(display, "%s\n", colorize(colorRed, fmt.Sprintf("Failed to delete: %d emails", len(deleteErrors))))
+		fmt.Fprintf(display, "Error details:\n")
 		for _, errMsg := range deleteErrors {
-			fmt.Printf("- %s\n", errMsg)
+			fmt.Fprintf(display, "- %s\n", colorize(colorRed, errMsg))
 		}
-		return fmt.Errorf("some deletions failed: %d errors occurred", len(deleteErrors))
+		return DeletionResult{Deleted: successCount, Errors: deleteErrors, Ids: trashed}, fmt.Errorf("some deletions failed: %d errors occurred", len(deleteErrors))
 	}
 
-	return nil
+	return DeletionResult{Deleted: successCount, Errors: deleteErrors, Ids: trashed}, nil
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Exit codes, so scripts wrapping the tool can tell what went wrong
+const (
+	// Any error without a more specific code
+	exitError = 1
+
+	// The command line arguments were invalid
+	exitUsage = 2
+
+	// Gmail rejected the credentials or token, or authorisation failed
+	exitAuth = 3
+
+	// The run finished, but some emails could not be deleted or restored
+	exitPartialFailure = 4
+
+	// Gmail's API quota was exceeded and the retry budget ran out
+	exitQuota = 5
+
+	// The run was stopped by SIGINT or SIGTERM
+	interruptedExitCode = 130
+)
+
+// Number of emails which could not be deleted or restored this run
+var failedMessages int
+
+// Returns exitPartialFailure if any emails could not be deleted or restored
+// in a run which otherwise succeeded, or else the run's own exit code
+func partialFailureCode(code int) int {
+	if code == 0 && failedMessages > 0 {
+		logger.Warn("Some emails could not be processed", "failed", failedMessages)
+		return exitPartialFailure
+	}
+	return code
+}
+
+// Returns the exit code to use for a fatal error, based on any error in the log arguments
+func errorExitCode(args []any) int {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			switch {
+			case errors.Is(err, errInterrupted):
+				return interruptedExitCode
+			case authFailure(err):
+				return exitAuth
+			case quotaExceeded(err):
+				return exitQuota
+			}
+		}
+	}
+	return exitError
+}
+
+// Returns true if the error means the credentials or token were rejected
+func authFailure(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) {
+		return true
+	}
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized
+}
+
+// Returns true if the error means Gmail's API quota or rate limits were exceeded
+func quotaExceeded(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == http.StatusTooManyRequests {
+		return true
+	}
+	if apiErr.Code == http.StatusForbidden {
+		for _, item := range apiErr.Errors {
+			switch item.Reason {
+			case "rateLimitExceeded", "userRateLimitExceeded", "dailyLimitExceeded", "quotaExceeded":
+				return true
+			}
+		}
+	}
+	return false
+}
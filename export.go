@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Schema of the database written by 'export --sqlite'. Existing tables are
+// replaced, so exporting to the same file again gives a fresh copy
+const exportSchema = `DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS message_labels;
+CREATE TABLE messages (
+	id TEXT PRIMARY KEY,
+	thread_id TEXT,
+	sender TEXT,
+	display_name TEXT,
+	subject TEXT,
+	date TEXT,
+	size INTEGER,
+	unread INTEGER,
+	newsletter INTEGER,
+	list_id TEXT
+);
+CREATE TABLE message_labels (
+	message_id TEXT,
+	label_id TEXT,
+	label_name TEXT
+);
+CREATE INDEX messages_sender ON messages (sender);
+CREATE INDEX message_labels_message ON message_labels (message_id);
+`
+
+// Handles the 'export --sqlite <file>' command, which writes the metadata of
+// every email from the given senders, as scanned or loaded with
+// --from-snapshot, into a SQLite database for running your own queries. The
+// sqlite3 command line tool loads the data, so it must be installed
+func runExportCommand(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) error {
+	if opts.SQLite == "" {
+		return fmt.Errorf("usage: export --sqlite <file>")
+	}
+	sqlite, err := exec.LookPath("sqlite3")
+	if err != nil {
+		return fmt.Errorf("the sqlite3 command line tool is needed to write %s, but was not found: %w", opts.SQLite, err)
+	}
+	messages, err := exportMessages(ctx, srv, senderStats)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(sqlite, opts.SQLite)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	writeErr := writeExportSQL(stdin, ctx, srv, messages)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("sqlite3 failed: %w", err)
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	fmt.Fprintf(display, "Exported %d emails to %s\n", len(messages), opts.SQLite)
+	return nil
+}
+
+// Returns the metadata of every email from the given senders, newest first.
+// Emails are read from the message cache, and any it does not have, such as
+// those in an older snapshot, are fetched
+func exportMessages(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) ([]*gmail.Message, error) {
+	cache := readCache()
+	defer startWork()()
+	var messages []*gmail.Message
+	for _, sender := range senderStats {
+		for _, id := range sender.Ids {
+			if interrupted() {
+				return nil, errInterrupted
+			}
+			if message, ok := cache.Messages[id]; ok {
+				messages = append(messages, message)
+				continue
+			}
+			message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata"))
+			if err != nil {
+				logger.Warn("Could not get email metadata, leaving it out of the export", "id", id, "err", err)
+				continue
+			}
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].InternalDate > messages[j].InternalDate
+	})
+	return messages, nil
+}
+
+// Write the SQL creating the export tables and inserting every email into them
+func writeExportSQL(w io.Writer, ctx context.Context, srv *gmail.Service, messages []*gmail.Message) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "BEGIN;\n%s", exportSchema)
+	for _, message := range messages {
+		headers := messageHeaders(message)
+		sender, name := extractEmail(headers["from"]), ""
+		if address, err := mail.ParseAddress(headers["from"]); err == nil && !opts.Redact {
+			name = address.Name
+		}
+		date := ""
+		if received := messageTime(message); !received.IsZero() {
+			date = received.UTC().Format(time.RFC3339)
+		}
+		newsletter := headers["list-unsubscribe"] != "" || headers["list-id"] != ""
+
+		fmt.Fprintf(out, "INSERT INTO messages VALUES (%s, %s, %s, %s, %s, %s, %d, %d, %d, %s);\n",
+			sqlString(message.Id), sqlString(message.ThreadId), sqlString(redactAddress(sender)), sqlString(name),
+			sqlString(redactSubject(headers["subject"])), sqlString(date), message.SizeEstimate,
+			sqlBool(slices.Contains(message.LabelIds, "UNREAD")), sqlBool(newsletter), sqlString(headers["list-id"]))
+		for _, label := range message.LabelIds {
+			fmt.Fprintf(out, "INSERT INTO message_labels VALUES (%s, %s, %s);\n", sqlString(message.Id), sqlString(label), sqlString(labelName(ctx, srv, label)))
+		}
+	}
+	fmt.Fprintf(out, "COMMIT;\n")
+	return out.Flush()
+}
+
+// Quote a string as a SQL literal
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Returns a boolean as the integer SQLite stores it as
+func sqlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Share of a sender's emails which must have skipped the inbox unread for
+// them to count as filtered away and never opened
+const filteredShare = 0.9
+
+// Fewest emails a sender must have sent to count as filtered away, so one
+// unread email in an old label does not count
+const filteredMinEmails = 3
+
+// Deletion score given at least to senders whose mail is filtered away and
+// never opened, as nobody is reading it
+const filteredScore = 0.9
+
+// Returns true if nearly all of the sender's emails are outside the inbox and
+// still unread, which means a filter or the category tabs move them away and
+// they are never opened. These senders are prime candidates for deleting and
+// unsubscribing from
+func isFilteredUnread(sender SenderStats) bool {
+	return sender.Count >= filteredMinEmails && float64(sender.ArchivedUnread) >= filteredShare*float64(sender.Count)
+}
+
+// List the senders whose mail is filtered away and never opened, largest first
+func printFilteredUnread(senderStats []SenderStats) {
+	table := newTable("Sender", "Emails", "Size", "Last seen").AlignRight(1, 2)
+	found := 0
+	for _, sender := range senderStats {
+		if isFilteredUnread(sender) && !isProtectedSender(sender) {
+			table.AddRow(redactAddress(sender.Email), strconv.Itoa(sender.Count), formatSize(sender.Size), formatDate(sender.LastSeen))
+			found++
+		}
+	}
+	if found == 0 {
+		return
+	}
+	fmt.Fprintf(display, "\n%d senders are filtered out of the inbox and never read, so are good candidates to delete and unsubscribe from:\n", found)
+	table.Render(display)
+}
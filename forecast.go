@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Number of complete months of history used to work out the growth trend
+const forecastMonths = 12
+
+// Number of senders shown as suggestions for slowing down mailbox growth
+const forecastSuggestions = 5
+
+// Returns the average number of bytes per month received over the
+// forecastMonths complete months before now, from the given monthly totals
+func monthlyGrowth(monthly map[string]int64, now time.Time) int64 {
+	var total int64
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	for i := 1; i <= forecastMonths; i++ {
+		month := firstOfMonth.AddDate(0, -i, 0).Format("2006-01")
+		total += monthly[month]
+	}
+	return total / forecastMonths
+}
+
+// Returns the number of months until the free space is used up at the given
+// growth rate, or -1 if the mailbox is not growing
+func monthsUntilFull(free, growth int64) float64 {
+	if growth <= 0 {
+		return -1
+	}
+	return float64(free) / float64(growth)
+}
+
+// Describe how long until the quota is reached, given a number of months
+func describeMonths(months float64, now time.Time) string {
+	if months < 0 {
+		return "never at the current rate"
+	}
+	if months == 0 {
+		return "already reached"
+	}
+	when := now.AddDate(0, int(months), int((months-float64(int(months)))*30))
+	return fmt.Sprintf("in %.1f months (around %s)", months, when.Format("January 2006"))
+}
+
+// Print a projection of when the mailbox will reach its storage quota,
+// and which senders would most delay that if their mail was deleted
+func printForecast(senderStats []SenderStats, now time.Time) {
+	// The quota is validated when the arguments are parsed
+	quota, _ := parseSize(opts.Quota)
+
+	// Work out current usage and the overall growth trend
+	var used, growth int64
+	allMonthly := make(map[string]int64)
+	for _, sender := range senderStats {
+		used += sender.Size
+		for month, bytes := range sender.MonthlyBytes {
+			allMonthly[month] += bytes
+		}
+	}
+	growth = monthlyGrowth(allMonthly, now)
+
+	free := max(quota-used, 0)
+	months := monthsUntilFull(free, growth)
+
+	fmt.Fprintf(display, "\nStorage forecast:\n")
+	fmt.Fprintf(display, "Mail storage used: %s of %s\n", formatSize(used), formatSize(quota))
+	fmt.Fprintf(display, "Average growth over the last %d months: %s per month\n", forecastMonths, formatSize(growth))
+	fmt.Fprintf(display, "Quota will be reached %s\n", describeMonths(months, now))
+
+	// Rank senders by how much of the growth they are responsible for,
+	// as deleting their mail as it arrives would slow the growth the most
+	type suggestion struct {
+		email  string
+		growth int64
+	}
+	var suggestions []suggestion
+	for _, sender := range senderStats {
+		if g := monthlyGrowth(sender.MonthlyBytes, now); g > 0 {
+			suggestions = append(suggestions, suggestion{sender.Email, g})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return suggestions[i].growth > suggestions[j].growth
+	})
+
+	if len(suggestions) == 0 {
+		return
+	}
+
+	fmt.Fprintf(display, "\nSenders whose mail would most change the forecast if it was deleted automatically:\n")
+	table := newTable("#", "Sender", "Growth per month", "Quota reached").AlignRight(0, 2)
+	for i, s := range suggestions[:min(len(suggestions), forecastSuggestions)] {
+		newMonths := monthsUntilFull(free, growth-s.growth)
+		table.AddRow(strconv.Itoa(i+1), redactAddress(s.email), formatSize(s.growth), describeMonths(newMonths, now))
+	}
+	table.Render(display)
+}
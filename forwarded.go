@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Address of the account being scanned, found from its profile during the scan
+var accountEmail string
+
+// Returns every value of the named header on an email, in the order they appear
+func headerValues(message *gmail.Message, name string) []string {
+	var values []string
+	if message.Payload == nil {
+		return nil
+	}
+	for _, header := range message.Payload.Headers {
+		if strings.EqualFold(header.Name, name) {
+			values = append(values, header.Value)
+		}
+	}
+	return values
+}
+
+// Returns the address of the account an email was auto-forwarded from, or an
+// empty string if it was delivered straight to this account. Forwarded emails
+// carry an X-Forwarded-For header, or a Delivered-To header for another address
+func forwardedFrom(message *gmail.Message) string {
+	for _, value := range headerValues(message, "X-Forwarded-For") {
+		// The header holds the original address followed by the address it was forwarded to
+		if fields := strings.Fields(value); len(fields) > 0 {
+			return strings.ToLower(fields[0])
+		}
+	}
+
+	if accountEmail == "" {
+		return ""
+	}
+	for _, value := range headerValues(message, "Delivered-To") {
+		address := strings.ToLower(extractEmail(strings.TrimSpace(value)))
+		if address != "" && address != strings.ToLower(accountEmail) {
+			return address
+		}
+	}
+	return ""
+}
+
+// Print the streams of email forwarded from other accounts, and offer to
+// delete whole streams. A stream can also be stopped by turning off
+// forwarding in the other account's settings
+func runForwardedCommand(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) error {
+	type stream struct {
+		account string
+		count   int
+		senders int
+	}
+	streamMap := make(map[string]*stream)
+	protectedIDs := make(map[string]bool)
+	for _, sender := range senderStats {
+		if isProtectedSender(sender) {
+			for _, id := range sender.Ids {
+				protectedIDs[id] = true
+			}
+		}
+		for account, count := range sender.ForwardedFrom {
+			s, exists := streamMap[account]
+			if !exists {
+				s = &stream{account: account}
+				streamMap[account] = s
+			}
+			s.count += count
+			s.senders++
+		}
+	}
+
+	var streams []*stream
+	for _, s := range streamMap {
+		streams = append(streams, s)
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].count > streams[j].count
+	})
+
+	if len(streams) == 0 {
+		fmt.Fprintf(display, "No emails forwarded from other accounts were found\n")
+		return nil
+	}
+
+	fmt.Fprintf(display, "\nEmails forwarded from other accounts:\n")
+	table := newTable("#", "Forwarded from", "Emails", "Senders").AlignRight(0, 2, 3)
+	for i, s := range streams {
+		table.AddRow(strconv.Itoa(i+1), redactAddress(s.account), strconv.Itoa(s.count), strconv.Itoa(s.senders))
+	}
+	table.Render(display)
+	fmt.Fprintf(display, "To stop a stream at the source, turn off forwarding in that account's settings.\n")
+
+	for {
+		fmt.Fprintf(display, "Which forwarded streams would you like to delete? (numbers e.g. 1,3-5/none):\n")
+		response, _ := readPrompt("select")
+		response = strings.ToLower(response)
+		if response == "none" || response == "" {
+			return nil
+		}
+
+		selected, err := parseSelection(response, len(streams))
+		if err != nil {
+			fmt.Fprintf(display, "Invalid selection: %v\n", err)
+			continue
+		}
+		for _, n := range selected {
+			account := streams[n-1].account
+			terms := []string{"deliveredto:" + account}
+			for _, sender := range opts.Protected {
+				terms = append(terms, "-from:"+sender)
+			}
+			found, err := listMessageIds(ctx, srv, strings.Join(terms, " "))
+			if err != nil {
+				logger.Error("Unable to find forwarded emails", "account", account, "err", err)
+				continue
+			}
+
+			// Protected senders' emails are never deleted, including those
+			// from addresses merged into a protected sender
+			var ids []string
+			for _, id := range found {
+				if !protectedIDs[id] {
+					ids = append(ids, id)
+				}
+			}
+			fmt.Fprintf(display, "Deleting %d emails forwarded from %s...\n", len(ids), redactAddress(account))
+			if _, err := deleteEmails(ctx, srv, account, ids); err != nil {
+				logger.Error("Error deleting forwarded emails", "account", account, "err", err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Number of senders shown when the heatmap command is not given a sender
+const heatmapSenders = 5
+
+// Characters used to shade heatmap cells, from no email to the busiest hour
+var heatmapShades = []string{" ", "░", "▒", "▓", "█"}
+
+// Handles the 'heatmap [sender]' command, which shows when email arrives by
+// day of the week and hour, for the whole mailbox and the top senders, or
+// for one sender. Mail from people tends to be spread around the working
+// day, while automated mail arrives in the same few hours every time
+func runHeatmapCommand(senderStats []SenderStats, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: heatmap [sender]")
+	}
+
+	if len(args) == 1 {
+		for _, sender := range senderStats {
+			if strings.EqualFold(sender.Email, args[0]) {
+				printHeatmap(display, redactAddress(sender.Email), sender.Heatmap)
+				return nil
+			}
+		}
+		return fmt.Errorf("no email found from %s", args[0])
+	}
+
+	var overall [7][24]int
+	for _, sender := range senderStats {
+		for day := range sender.Heatmap {
+			for hour, count := range sender.Heatmap[day] {
+				overall[day][hour] += count
+			}
+		}
+	}
+	printHeatmap(display, "All senders", overall)
+	for _, sender := range senderStats[:min(len(senderStats), heatmapSenders)] {
+		printHeatmap(display, redactAddress(sender.Email), sender.Heatmap)
+	}
+	return nil
+}
+
+// Returns the share of emails which arrived in the busiest hour of the week.
+// A high share suggests the mail is sent on a schedule
+func peakShare(heatmap [7][24]int) float64 {
+	total, peak := 0, 0
+	for day := range heatmap {
+		for _, count := range heatmap[day] {
+			total += count
+			peak = max(peak, count)
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(peak) / float64(total)
+}
+
+// Draw a heatmap as a grid of days by hours, shaded relative to the busiest hour
+func printHeatmap(w io.Writer, title string, heatmap [7][24]int) {
+	peak := 0
+	for day := range heatmap {
+		for _, count := range heatmap[day] {
+			peak = max(peak, count)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%s (%.0f%% of emails in the busiest hour):\n", title, peakShare(heatmap)*100)
+	fmt.Fprintf(w, "    ")
+	for hour := 0; hour < 24; hour += 3 {
+		fmt.Fprintf(w, "%-3d", hour)
+	}
+	fmt.Fprintf(w, "\n")
+
+	// Weeks are shown starting on Monday
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		fmt.Fprintf(w, "%s ", day.String()[:3])
+		for _, count := range heatmap[day] {
+			shade := 0
+			if count > 0 {
+				shade = 1 + (count*(len(heatmapShades)-2))/peak
+			}
+			fmt.Fprintf(w, "%s", heatmapShades[shade])
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
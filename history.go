@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Label IDs of the system labels named in scanExcludedLabels
+var scanExcludedLabelIDs = map[string]string{
+	"sent":   "SENT",
+	"drafts": "DRAFT",
+	"chats":  "CHAT",
+	"spam":   "SPAM",
+	"trash":  "TRASH",
+}
+
+// Returned when Gmail no longer has the history since the cache was saved,
+// which happens after about a week, so a full scan is needed
+var errHistoryExpired = errors.New("mailbox history has expired")
+
+// Bring the cache up to date with the changes made to the mailbox since it
+// was saved, using the history API: new emails are fetched, deleted emails
+// are removed and changed labels are updated, fetching any email not yet
+// cached. This is far quicker than listing the whole mailbox again
+func applyHistory(ctx context.Context, srv *gmail.Service, cache *MessageCache) error {
+	added, deleted, relabelled := 0, 0, 0
+	pageToken := ""
+	for {
+		req := srv.Users.History.List("me").StartHistoryId(cache.HistoryID)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		r, err := callAPI(ctx, "history.list", req)
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return errHistoryExpired
+		} else if err != nil {
+			return err
+		}
+
+		for _, record := range r.History {
+			if interrupted() {
+				return errInterrupted
+			}
+			for _, change := range record.MessagesAdded {
+				if fetchHistoryMessage(ctx, srv, cache, change.Message.Id) {
+					added++
+				}
+			}
+			for _, change := range record.MessagesDeleted {
+				delete(cache.Messages, change.Message.Id)
+				deleted++
+			}
+
+			// Emails the cache has not seen, such as ones moved out of the Spam
+			// or Trash, are fetched so the scan now includes them
+			var changes []*gmail.Message
+			for _, change := range record.LabelsAdded {
+				changes = append(changes, change.Message)
+			}
+			for _, change := range record.LabelsRemoved {
+				changes = append(changes, change.Message)
+			}
+			for _, changed := range changes {
+				if message, ok := cache.Messages[changed.Id]; ok {
+					message.LabelIds = changed.LabelIds
+					relabelled++
+				} else if fetchHistoryMessage(ctx, srv, cache, changed.Id) {
+					added++
+				}
+			}
+		}
+
+		if r.NextPageToken == "" {
+			if r.HistoryId != 0 {
+				cache.HistoryID = r.HistoryId
+			}
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+	logger.Info("Updated scan from mailbox history", "new", added, "deleted", deleted, "label_changes", relabelled)
+	return nil
+}
+
+// Returns the cached emails a full scan would have listed, newest first. The
+// history includes every email in the mailbox, so emails in the labels left
+// out of the scan are skipped here instead of by the search query
+func cachedScanMessages(cache *MessageCache) []*gmail.Message {
+	included := make(map[string]bool)
+	for _, label := range opts.IncludeLabels {
+		included[strings.ToLower(label)] = true
+	}
+	var excluded []string
+	for _, label := range scanExcludedLabels {
+		if !included[label] {
+			excluded = append(excluded, scanExcludedLabelIDs[label])
+		}
+	}
+
+	var messages []*gmail.Message
+	for _, message := range cache.Messages {
+		if !slices.ContainsFunc(message.LabelIds, func(id string) bool { return slices.Contains(excluded, id) }) {
+			messages = append(messages, message)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].InternalDate > messages[j].InternalDate
+	})
+	return messages
+}
+
+// Fetch an email named in the mailbox history and add it to the cache,
+// returning whether it could be fetched
+func fetchHistoryMessage(ctx context.Context, srv *gmail.Service, cache *MessageCache, id string) bool {
+	message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata"))
+	if err != nil {
+		// The email may have been deleted again since it changed
+		logger.Debug("Could not get email from history, skipping", "id", id, "err", err)
+		return false
+	}
+	cacheMessage(cache, message)
+	return true
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// Returns a Gmail service whose history.list calls are answered with the
+// given pages in turn, and whose messages.get calls return the given emails
+func historyService(t *testing.T, pages []*gmail.ListHistoryResponse, messages map[string]*gmail.Message) *gmail.Service {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/gmail/v1/users/me/")
+		var response any
+		switch {
+		case path == "history" && pages == nil:
+			http.Error(w, `{"error": {"code": 404, "message": "Requested entity was not found."}}`, http.StatusNotFound)
+			return
+		case path == "history":
+			if start := r.URL.Query().Get("startHistoryId"); start != "7" {
+				t.Errorf("history listed from %q, want 7", start)
+			}
+			// Each page token is the index of the next page
+			page, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+			response = pages[page]
+		case strings.HasPrefix(path, "messages/"):
+			message, ok := messages[strings.TrimPrefix(path, "messages/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			response = message
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	srv, err := gmail.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestApplyHistory(t *testing.T) {
+	setOptions(t, Options{})
+	message := func(id string, labels ...string) *gmail.Message {
+		return &gmail.Message{Id: id, LabelIds: labels, InternalDate: 1700000000000}
+	}
+
+	pages := []*gmail.ListHistoryResponse{
+		{
+			History: []*gmail.History{{
+				MessagesAdded:   []*gmail.HistoryMessageAdded{{Message: &gmail.Message{Id: "new"}}},
+				MessagesDeleted: []*gmail.HistoryMessageDeleted{{Message: &gmail.Message{Id: "gone"}}},
+			}},
+			NextPageToken: "1",
+		},
+		{
+			History: []*gmail.History{
+				{LabelsAdded: []*gmail.HistoryLabelAdded{{Message: message("kept", "INBOX", "STARRED")}}},
+				{LabelsRemoved: []*gmail.HistoryLabelRemoved{{Message: message("restored", "INBOX")}}},
+				{MessagesAdded: []*gmail.HistoryMessageAdded{{Message: &gmail.Message{Id: "deleted-again"}}}},
+			},
+			HistoryId: 42,
+		},
+	}
+	srv := historyService(t, pages, map[string]*gmail.Message{
+		"new":      message("new", "INBOX", "UNREAD"),
+		"restored": message("restored", "INBOX"),
+	})
+
+	cache := &MessageCache{
+		HistoryID: 7,
+		Messages: map[string]*gmail.Message{
+			"gone": message("gone", "INBOX"),
+			"kept": message("kept", "INBOX"),
+		},
+	}
+	if err := applyHistory(context.Background(), srv, cache); err != nil {
+		t.Fatalf("applyHistory() = %v", err)
+	}
+
+	var ids []string
+	for id := range cache.Messages {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	if want := []string{"kept", "new", "restored"}; !slices.Equal(ids, want) {
+		t.Errorf("cached emails = %v, want %v", ids, want)
+	}
+	if labels := cache.Messages["kept"].LabelIds; !slices.Equal(labels, []string{"INBOX", "STARRED"}) {
+		t.Errorf("kept email labels = %v, want [INBOX STARRED]", labels)
+	}
+	if cache.HistoryID != 42 {
+		t.Errorf("history ID = %d, want 42", cache.HistoryID)
+	}
+}
+
+// Gmail only keeps about a week of history, after which a full scan is needed
+func TestApplyHistoryExpired(t *testing.T) {
+	setOptions(t, Options{})
+	srv := historyService(t, nil, nil)
+	cache := &MessageCache{HistoryID: 7, Messages: map[string]*gmail.Message{}}
+	if err := applyHistory(context.Background(), srv, cache); !errors.Is(err, errHistoryExpired) {
+		t.Errorf("applyHistory() = %v, want %v", err, errHistoryExpired)
+	}
+	if cache.HistoryID != 7 {
+		t.Errorf("history ID = %d, want it left at 7", cache.HistoryID)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Returns the time before which a sender's last email must have arrived for
+// them to count as inactive, or false if --inactive-after is turned off
+func inactiveCutoff(now time.Time) (time.Time, bool) {
+	if opts.InactiveAfter == "" || opts.InactiveAfter == "0" {
+		return time.Time{}, false
+	}
+	age, err := parseAge(opts.InactiveAfter)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return now.Add(-age), true
+}
+
+// Returns true if the sender has not sent anything for longer than --inactive-after
+func isInactive(sender SenderStats, now time.Time) bool {
+	cutoff, ok := inactiveCutoff(now)
+	return ok && !sender.LastSeen.IsZero() && sender.LastSeen.Before(cutoff)
+}
+
+// Handles the 'inactive [age]' command, which lists the senders who have not
+// sent anything for longer than --inactive-after, and offers to delete all of
+// their emails in one go, or with an age given only those older than it
+func runInactiveCommand(ctx context.Context, srv *gmail.Service, senderStats []SenderStats, args []string, now time.Time) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: inactive [age]")
+	}
+	if _, ok := inactiveCutoff(now); !ok {
+		return fmt.Errorf("no inactivity threshold set, use --inactive-after")
+	}
+	var olderThan time.Duration
+	if len(args) == 1 {
+		var err error
+		if olderThan, err = parseAge(args[0]); err != nil {
+			return err
+		}
+	}
+
+	var inactive []SenderStats
+	total := 0
+	var totalSize int64
+	for _, sender := range senderStats {
+		if isInactive(sender, now) && !isProtectedSender(sender) {
+			inactive = append(inactive, sender)
+			total += sender.Count
+			totalSize += sender.Size
+		}
+	}
+	if len(inactive) == 0 {
+		fmt.Fprintf(display, "No senders have been inactive for longer than %s\n", opts.InactiveAfter)
+		return nil
+	}
+
+	fmt.Fprintf(display, "\n%d senders have sent nothing for longer than %s, with %d emails between them:\n", len(inactive), opts.InactiveAfter, total)
+	table := newTable("#", "Sender", "Emails", "Size", "Last seen").AlignRight(0, 2, 3)
+	for i, sender := range inactive {
+		table.AddRow(strconv.Itoa(i+1), redactAddress(sender.Email), strconv.Itoa(sender.Count), formatSize(sender.Size), formatDate(sender.LastSeen))
+	}
+	table.Render(display)
+
+	if olderThan > 0 {
+		fmt.Fprintf(display, "Would you like to delete these senders' emails from before %s? (yes/no):\n", formatDate(now.Add(-olderThan)))
+	} else {
+		fmt.Fprintf(display, "Would you like to delete all of these senders' emails, freeing about %s? (yes/no):\n", formatSize(totalSize))
+	}
+	response, _ := readPrompt("confirm")
+	if strings.ToLower(response) != "yes" {
+		return nil
+	}
+
+	for _, sender := range inactive {
+		if interrupted() {
+			return nil
+		}
+		if olderThan == 0 {
+			deleteSender(ctx, srv, sender)
+			continue
+		}
+
+		// Only the emails older than the given age are deleted, which Gmail finds for us
+		query := fmt.Sprintf("from:%s before:%s", sender.Email, now.Add(-olderThan).Format("2006/01/02"))
+		ids, err := listMessageIds(ctx, srv, query)
+		if err != nil {
+			logger.Error("Could not list the sender's older emails", "sender", sender.Email, "err", err)
+			continue
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		fmt.Fprintf(display, "Deleting %d emails from %s...\n", len(ids), redactAddress(sender.Email))
+		if _, err := deleteEmails(ctx, srv, sender.Email, ids); err != nil {
+			logger.Error("Error deleting emails", "sender", sender.Email, "err", err)
+		}
+	}
+	return nil
+}
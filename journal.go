@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// A record of one message the tool has acted on. Run identifies the
+// run of the tool which did it, so a whole run can be looked up later
+type JournalEntry struct {
+	Run    string    `json:"run"`
+	ID     string    `json:"id"`
+	Sender string    `json:"sender"`
+	Action string    `json:"action"`
+	Time   time.Time `json:"time"`
+}
+
+// Identifies this run of the tool in the journal
+var runID = time.Now().Format("20060102-150405")
+
+// Returns the path of the journal for the selected profile
+func journalPath() string {
+	return profileFile("journal", ".jsonl")
+}
+
+// Append a record of the given messages to the journal. The journal is
+// best effort, so failing to write it is reported but does not stop the run
+func appendJournal(action, sender string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+
+	file, err := os.OpenFile(journalPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		logger.Warn("Unable to open journal", "err", err)
+		return
+	}
+	defer file.Close()
+
+	now := time.Now()
+	encoder := json.NewEncoder(file)
+	for _, id := range ids {
+		entry := JournalEntry{Run: runID, ID: id, Sender: sender, Action: action, Time: now}
+		if err := encoder.Encode(entry); err != nil {
+			logger.Warn("Unable to write to journal", "err", err)
+			return
+		}
+	}
+}
+
+// Read every entry in the journal. A missing journal just has no entries
+func readJournal() ([]JournalEntry, error) {
+	file, err := os.Open(journalPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Number of keywords shown for each sender at the prompt
+const topKeywordCount = 5
+
+// Common words which say nothing about what kind of mail a sender sends
+var stopWords = []string{
+	"a", "an", "and", "are", "at", "be", "by", "for", "from", "has", "have", "in", "is", "it",
+	"of", "on", "or", "our", "the", "this", "to", "we", "with", "you", "your", "new", "now",
+}
+
+// Keywords of transactional emails, such as receipts and security alerts,
+// which are usually worth keeping even from senders of mail which is not
+var transactionalKeywords = []string{
+	"invoice", "receipt", "order", "shipped", "delivered", "delivery", "payment", "statement",
+	"security", "alert", "password", "verification", "verify", "confirmation", "booking", "refund",
+}
+
+// Count the words and two word phrases in a sender's subject lines,
+// weighted by how many emails had each subject
+func subjectKeywords(sender SenderStats) map[string]int {
+	keywords := make(map[string]int)
+	for subject, count := range sender.Subjects {
+		words := strings.FieldsFunc(strings.ToLower(subject), func(r rune) bool {
+			return !unicode.IsLetter(r) && r != '\''
+		})
+		var previous string
+		for _, word := range words {
+			word = strings.Trim(word, "'")
+			if len([]rune(word)) < 3 || slices.Contains(stopWords, word) {
+				previous = ""
+				continue
+			}
+			keywords[word] += count
+			if previous != "" {
+				keywords[previous+" "+word] += count
+			}
+			previous = word
+		}
+	}
+	return keywords
+}
+
+// Describe the words and phrases which dominate a sender's subject lines,
+// e.g. "weekly digest ×212, offer ×40", and whether any of them are
+// transactional keywords, which are marked with a !
+func describeKeywords(sender SenderStats) (string, bool) {
+	keywords := subjectKeywords(sender)
+	candidates := make([]string, 0, len(keywords))
+	for keyword := range keywords {
+		candidates = append(candidates, keyword)
+	}
+
+	// Phrases sort ahead of the words in them when they are as common, so the word can be left out
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if keywords[a] != keywords[b] {
+			return keywords[a] > keywords[b]
+		}
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+
+	var chosen []string
+	transactional := false
+	for _, keyword := range candidates {
+		if len(chosen) == topKeywordCount {
+			break
+		}
+		if slices.ContainsFunc(chosen, func(phrase string) bool {
+			return keywords[phrase] == keywords[keyword] && slices.Contains(strings.Fields(phrase), keyword)
+		}) {
+			continue
+		}
+		chosen = append(chosen, keyword)
+	}
+
+	parts := make([]string, len(chosen))
+	for i, keyword := range chosen {
+		marker := ""
+		if slices.ContainsFunc(strings.Fields(keyword), func(word string) bool { return slices.Contains(transactionalKeywords, word) }) {
+			marker = "!"
+			transactional = true
+		}
+		parts[i] = fmt.Sprintf("%s%s ×%d", marker, keyword, keywords[keyword])
+	}
+	return strings.Join(parts, ", "), transactional
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Caches of label names to IDs and IDs to names, filled in the first time a label is looked up
+var (
+	labelIDs   map[string]string
+	labelNames map[string]string
+)
+
+// Fetch every label in the mailbox into the caches, if they have not been already
+func loadLabels(ctx context.Context, srv *gmail.Service) error {
+	if labelIDs != nil {
+		return nil
+	}
+	r, err := callAPI(ctx, "labels.list", srv.Users.Labels.List("me"))
+	if err != nil {
+		return err
+	}
+	labelIDs = make(map[string]string)
+	labelNames = make(map[string]string)
+	for _, label := range r.Labels {
+		labelIDs[strings.ToLower(label.Name)] = label.Id
+		labelNames[label.Id] = label.Name
+	}
+	return nil
+}
+
+// Returns the ID of the label with the given name. System labels such as
+// INBOX and UNREAD use their name as their ID. If create is set, a user
+// label which does not exist yet is created
+func labelID(ctx context.Context, srv *gmail.Service, name string, create bool) (string, error) {
+	if err := loadLabels(ctx, srv); err != nil {
+		return "", err
+	}
+
+	if id, ok := labelIDs[strings.ToLower(name)]; ok {
+		return id, nil
+	}
+	if !create {
+		return "", fmt.Errorf("no label called %q", name)
+	}
+
+	label, err := callWriteAPI(ctx, "labels.create", srv.Users.Labels.Create("me", &gmail.Label{
+		Name:                  name,
+		LabelListVisibility:   "labelShow",
+		MessageListVisibility: "show",
+	}))
+	if err != nil {
+		return "", fmt.Errorf("could not create label %q: %w", name, err)
+	}
+	logger.Info("Created label", "name", name)
+	labelIDs[strings.ToLower(name)] = label.Id
+	labelNames[label.Id] = name
+	return label.Id, nil
+}
+
+// Number of labels listed for each sender at the prompt
+const senderLabelCount = 4
+
+// Describe the labels which most of a sender's emails carry, with the share
+// of their emails carrying each, e.g. "INBOX 80%, CATEGORY_PROMOTIONS 75%".
+// UNREAD is left out, as the unread share is already shown
+func describeLabels(ctx context.Context, srv *gmail.Service, sender SenderStats) string {
+	var ids []string
+	for id := range sender.Labels {
+		if id != "UNREAD" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if sender.Labels[ids[i]] != sender.Labels[ids[j]] {
+			return sender.Labels[ids[i]] > sender.Labels[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	var parts []string
+	for _, id := range ids[:min(len(ids), senderLabelCount)] {
+		share := float64(sender.Labels[id]) / float64(max(sender.Count, 1))
+		parts = append(parts, labelName(ctx, srv, id)+" "+formatPercent(share))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Returns the name of the label with the given ID, or the ID itself if the label is unknown
+func labelName(ctx context.Context, srv *gmail.Service, id string) string {
+	if err := loadLabels(ctx, srv); err != nil {
+		logger.Warn("Unable to list labels", "err", err)
+		return id
+	}
+	if name, ok := labelNames[id]; ok {
+		return name
+	}
+	return id
+}
+
+// Look up the IDs of several labels
+func labelIDList(ctx context.Context, srv *gmail.Service, names []string, create bool) ([]string, error) {
+	var ids []string
+	for _, name := range names {
+		id, err := labelID(ctx, srv, name, create)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Describe how many of a sender's emails are starred or marked important, e.g.
+// "3 starred, 12 important", or "" if none are. These are worth a second look
+// before deleting everything from the sender
+func describeKept(sender SenderStats) string {
+	var parts []string
+	if n := sender.Labels["STARRED"]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d starred", n))
+	}
+	if n := sender.Labels["IMPORTANT"]; n > 0 {
+		parts = append(parts, fmt.Sprintf("%d important", n))
+	}
+	return strings.Join(parts, ", ")
+}
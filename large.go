@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// An individual email found by the large-message finder
+type largeMessage struct {
+	id          string
+	sender      string
+	subject     string
+	date        string
+	size        int64
+	attachments []string
+}
+
+// Handles 'scan --larger-than <size>', which lists the biggest individual
+// emails in the mailbox regardless of sender, with their subjects and
+// attachment names, and offers to move a selection of them to the Trash
+func runLargeMessagesCommand(ctx context.Context, srv *gmail.Service) error {
+	minSize, err := parseSize(opts.LargerThan)
+	if err != nil {
+		return err
+	}
+
+	// Let Gmail do the filtering, so only matching emails need to be fetched
+	query, _ := scanQuery()
+	ids, err := listMessageIds(ctx, srv, strings.TrimSpace(fmt.Sprintf("%s larger:%d", query, minSize)))
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "No emails larger than %s\n", formatSize(minSize))
+		return nil
+	}
+
+	defer startWork()()
+	var messages []largeMessage
+	progress := newProgress("Fetching large emails", int64(len(ids)))
+	for _, id := range ids {
+		if interrupted() {
+			break
+		}
+		message, err := fetchLargeMessage(ctx, srv, id)
+		progress.Add(1)
+		if err != nil {
+			logger.Warn("Could not get email details, continuing", "id", id, "err", err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	progress.Finish()
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].size > messages[j].size
+	})
+
+	fmt.Fprintf(display, "\nEmails larger than %s, largest first:\n", formatSize(minSize))
+	table := newTable("#", "Size", "Date", "Sender", "Subject", "Attachments").AlignRight(0, 1)
+	for i, message := range messages {
+		table.AddRow(strconv.Itoa(i+1), formatSize(message.size), message.date, redactAddress(message.sender),
+			redactSubject(message.subject), strings.Join(message.attachments, ", "))
+	}
+	table.Render(display)
+
+	for {
+		fmt.Fprintf(display, "Which emails would you like to delete? (numbers e.g. 1,3-5/none):\n")
+		response, _ := readPrompt("select")
+		response = strings.ToLower(response)
+		if response == "none" || response == "" {
+			return nil
+		}
+
+		selected, err := parseSelection(response, len(messages))
+		if err != nil {
+			fmt.Fprintf(display, "Invalid selection: %v\n", err)
+			continue
+		}
+
+		// Emails are trashed a sender at a time, so the journal and deletion log record who sent them
+		var senders []string
+		bySender := make(map[string][]string)
+		for _, n := range selected {
+			message := messages[n-1]
+			if _, exists := bySender[message.sender]; !exists {
+				senders = append(senders, message.sender)
+			}
+			bySender[message.sender] = append(bySender[message.sender], message.id)
+		}
+		for _, sender := range senders {
+			fmt.Fprintf(display, "Deleting %d large emails from %s...\n", len(bySender[sender]), redactAddress(sender))
+			if _, err := deleteEmails(ctx, srv, sender, bySender[sender]); err != nil {
+				logger.Error("Error deleting large emails", "sender", sender, "err", err)
+			}
+			if interrupted() {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+// Fetch the sender, subject, date, size and attachment names of an email. Only
+// the headers and part filenames are requested, so bodies are not downloaded
+func fetchLargeMessage(ctx context.Context, srv *gmail.Service, id string) (largeMessage, error) {
+	message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("full").
+		Fields("id,internalDate,sizeEstimate,payload(headers,filename,parts(filename,parts(filename,parts(filename))))"))
+	if err != nil {
+		return largeMessage{}, err
+	}
+
+	headers := messageHeaders(message)
+	sender := "(unknown sender)"
+	if addresses := extractAddresses(headers["from"]); len(addresses) > 0 {
+		sender = addresses[0]
+	}
+	date := ""
+	if t := messageTime(message); !t.IsZero() {
+		date = formatDate(t)
+	}
+	return largeMessage{
+		id:          message.Id,
+		sender:      sender,
+		subject:     headers["subject"],
+		date:        date,
+		size:        message.SizeEstimate,
+		attachments: attachmentNames(message.Payload),
+	}, nil
+}
+
+// Returns the filenames of every attachment in a message part and the parts nested inside it
+func attachmentNames(part *gmail.MessagePart) []string {
+	var names []string
+	for _, attachment := range attachmentParts(part) {
+		names = append(names, attachment.Filename)
+	}
+	return names
+}
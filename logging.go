@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Logger for progress messages, warnings and errors. Prompts and reports are
+// written to display instead, so the two can be sent to different places
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// Set up the logger from the --verbose, --quiet and --log-file options
+func setupLogging() error {
+	level := slog.LevelInfo
+	if opts.Verbose {
+		level = slog.LevelDebug
+	}
+	if opts.Quiet {
+		level = slog.LevelWarn
+	}
+
+	var out io.Writer = os.Stderr
+	if opts.LogFile != "" {
+		file, err := os.OpenFile(opts.LogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		out = file
+	}
+
+	logger = slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: level, ReplaceAttr: redactLogAttr}))
+	return nil
+}
+
+// Where progress bars are drawn. They always go to the terminal on
+// stderr rather than the log file, and are hidden by --quiet
+func progressOutput() io.Writer {
+	if opts.Quiet {
+		return io.Discard
+	}
+	return os.Stderr
+}
+
+// The message of the error which ended the run, for the notification sent when it finishes
+var runFailure string
+
+// Log an error which ends the run, returning the exit code chosen from the
+// kind of error for run to return once its deferred clean up is done
+func failed(msg string, args ...any) int {
+	return failedCode(errorExitCode(args), msg, args...)
+}
+
+// Log an error which ends the run, returning the given exit code
+func failedCode(code int, msg string, args ...any) int {
+	logger.Error(msg, args...)
+	runFailure = msg
+	return code
+}
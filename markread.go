@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Mark every unread email from the given sender as read, without deleting anything
+func markSenderRead(ctx context.Context, srv *gmail.Service, sender SenderStats) {
+	if sender.Unread == 0 {
+		fmt.Fprintf(display, "All of the emails from %s have already been read\n", redactAddress(sender.Email))
+		return
+	}
+	logger.Info("Marking emails as read", "sender", sender.Email, "count", sender.Unread)
+	if err := modifyEmails(ctx, srv, sender.Email, sender.Ids, nil, []string{"UNREAD"}, "read"); err != nil {
+		logger.Error("Error marking emails as read", "sender", sender.Email, "err", err)
+		return
+	}
+	fmt.Fprintf(display, "%s\n", colorize(colorGreen, fmt.Sprintf("Marked %d emails from %s as read", sender.Unread, redactAddress(sender.Email))))
+}
+
+// Handles the 'mark-read --query <search>' command, which marks every unread
+// email matching a Gmail search (e.g. "from:news@store.com older_than:1y") as
+// read after confirming, to bring the unread count down without deleting anything
+func runMarkReadCommand(ctx context.Context, srv *gmail.Service, args []string) error {
+	if len(args) > 0 || opts.Query == "" {
+		return fmt.Errorf("usage: mark-read --query <gmail search>")
+	}
+	query := opts.Query
+	ids, err := listMessageIds(ctx, srv, "is:unread "+query)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "No unread emails match %q\n", query)
+		return nil
+	}
+
+	fmt.Fprintf(display, "Would you like to mark %d unread emails matching %q as read? (yes/no):\n", len(ids), query)
+	response, _ := readPrompt("confirm")
+	if strings.ToLower(response) != "yes" {
+		return nil
+	}
+	if err := modifyEmails(ctx, srv, "query:"+query, ids, nil, []string{"UNREAD"}, "read"); err != nil {
+		return err
+	}
+	fmt.Fprintf(display, "Marked %d emails as read\n", len(ids))
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Let the user know the run has finished, or failed with the given message,
+// with a desktop notification if --notify was given and a terminal bell if
+// --bell was given, so long runs do not need to be watched
+func notifyFinished(failure string) {
+	title := "email_deleter finished"
+	message := "The run has finished"
+	if failure != "" {
+		title = "email_deleter failed"
+		message = failure
+	} else if failedMessages > 0 {
+		message = fmt.Sprintf("The run has finished, but %d emails could not be processed", failedMessages)
+	}
+
+	if opts.Bell {
+		fmt.Fprint(os.Stderr, "\a")
+	}
+	if opts.Notify {
+		if err := desktopNotification(title, message); err != nil {
+			logger.Warn("Unable to show desktop notification", "err", err)
+		}
+	}
+}
+
+// Show a native desktop notification using the platform's own tools
+func desktopNotification(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+		script := "Add-Type -AssemblyName System.Windows.Forms; " +
+			"$n = New-Object System.Windows.Forms.NotifyIcon; " +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; " +
+			"$n.ShowBalloonTip(10000, " + quote(title) + ", " + quote(message) + ", 'Info'); Start-Sleep -Seconds 5"
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", script)
+	default:
+		cmd = exec.Command("notify-send", "--app-name=email_deleter", title, message)
+	}
+	return cmd.Run()
+}
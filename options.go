@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Stores the options the program was run with
+type Options struct {
+	Output          string
+	Force           bool
+	Quota           string
+	ConfigPath      string
+	Profile         string
+	Scopes          []string
+	Protected       []string
+	RateLimit       time.Duration
+	CredentialsFile string
+	TokenFile       string
+	Top             int
+	StopBelow       int
+	PurgeAfter      string
+	MinCount        int
+	Verbose         bool
+	Quiet           bool
+	LogFile         string
+	NoColor         bool
+	SnapshotPath    string
+	FromSnapshot    string
+	Borders         bool
+	Stream          string
+	LargerThan      string
+	PageSize        int
+	RetryBudget     int
+	Preview         int
+	AccessToken     string
+	IncludeLabels   []string
+	PromptTimeout   time.Duration
+	DefaultAnswer   string
+	Sort            string
+	Redact          bool
+	HTML            string
+	Answers         string
+	DeletionLog     string
+	Notify          bool
+	Bell            bool
+	GroupBy         string
+	NewslettersOnly bool
+	CheckReplies    bool
+	Threads         bool
+	KeepAliases     bool
+	Storage         bool
+	InactiveAfter   string
+	NoCache         bool
+	SQLite          string
+	AutomatedOnly   bool
+	CCOnly          bool
+	Permanent       bool
+	OlderThan       string
+	KeepLatest      int
+	KeepStarred     bool
+	KeepAttachments bool
+	ExcludeQuery    string
+	Query           string
+}
+
+// System labels whose emails are left out of the scan unless named in --include-labels.
+// Sent mail and drafts are from the user, so would put their own address near the top
+var scanExcludedLabels = []string{"sent", "drafts", "chats", "spam", "trash"}
+
+// Options for the current run, filled in by parseArgs
+var opts Options
+
+// The commands the program accepts, and the subcommands each one takes.
+// Running with no command starts the interactive clean up
+var commands = []struct {
+	name        string
+	subcommands []string
+}{
+	{"accounts", nil},
+	{"attachments", nil},
+	{"bounces", []string{"delete"}},
+	{"categories", nil},
+	{"forecast", nil},
+	{"forwarded", nil},
+	{"delete", nil},
+	{"diff", nil},
+	{"domains", nil},
+	{"export", nil},
+	{"duplicates", nil},
+	{"report", nil},
+	{"scan", nil},
+	{"heatmap", nil},
+	{"inactive", nil},
+	{"labels", nil},
+	{"mark-read", nil},
+	{"tlds", nil},
+	{"trash", []string{"purge"}},
+	{"sent", nil},
+	{"show", nil},
+	{"spam", []string{"purge"}},
+	{"undo", nil},
+	{"state", []string{"export", "import"}},
+	{"completion", []string{"bash", "zsh", "fish"}},
+}
+
+// Create the flag set holding every flag the program accepts, bound to the given options
+func newFlagSet(o *Options) *flag.FlagSet {
+	fs := flag.NewFlagSet("email_deleter", flag.ContinueOnError)
+	fs.StringVar(&o.Output, "output", "text", "output format, either 'text' or 'json'")
+	fs.BoolVar(&o.Force, "force", false, "overwrite existing files when importing state")
+	fs.StringVar(&o.Quota, "quota", "15G", "storage quota of the account, used by the forecast command")
+	fs.StringVar(&o.ConfigPath, "config", defaultConfigPath(), "path to the config file")
+	fs.StringVar(&o.AccessToken, "access-token", "", "read an OAuth token obtained by another system from this file, or '-' for stdin, instead of authorising locally")
+	fs.StringVar(&o.Profile, "profile", "default", "name of the Gmail account profile to use")
+	fs.DurationVar(&o.RateLimit, "rate-limit", 100*time.Millisecond, "delay between deletion API calls")
+	fs.IntVar(&o.RetryBudget, "retry-budget", 20, "total number of times failed API calls may be retried in one run")
+	fs.BoolVar(&o.CheckReplies, "check-replies", false, "read the Sent folder to mark senders you have never written to")
+	fs.StringVar(&o.InactiveAfter, "inactive-after", "18mo", "mark senders who have sent nothing for this long as inactive (0 to turn off)")
+	fs.BoolVar(&o.Storage, "storage", false, "show the account's Google storage usage at the start of the run, which needs read access to Drive metadata")
+	fs.BoolVar(&o.KeepAliases, "keep-aliases", false, "treat every address as a separate sender, instead of merging addresses which obviously belong to one sender")
+	fs.StringVar(&o.OlderThan, "older-than", "", "only delete emails older than this age (e.g. 1y) or date (e.g. 2023-01-01), keeping more recent ones")
+	fs.BoolVar(&o.KeepStarred, "keep-starred", true, "never delete starred or important emails, or conversations containing them (--keep-starred=false to allow it)")
+	fs.StringVar(&o.Query, "query", "", "Gmail search selecting the emails the delete command removes (to the Trash, or for good with --permanent) or mark-read marks as read")
+	fs.StringVar(&o.ExcludeQuery, "exclude-query", "", "Gmail search for emails which are never deleted, e.g. 'label:receipts OR subject:invoice'")
+	fs.BoolVar(&o.KeepAttachments, "keep-attachments", false, "never delete emails with attachments, or conversations containing them")
+	fs.IntVar(&o.KeepLatest, "keep-latest", 0, "keep each deleted sender's most recent N emails, deleting the rest")
+	fs.BoolVar(&o.Permanent, "permanent", false, "delete emails permanently instead of moving them to the Trash, after an extra confirmation")
+	fs.BoolVar(&o.Threads, "threads", false, "count and delete whole conversations, each belonging to the sender who started it, instead of individual emails")
+	fs.BoolVar(&o.AutomatedOnly, "automated-only", false, "only prompt about automated senders, such as no-reply addresses")
+	fs.BoolVar(&o.CCOnly, "cc-only", false, "only prompt about senders who have only ever copied you in, never emailed you directly")
+	fs.BoolVar(&o.NewslettersOnly, "newsletters-only", false, "only prompt about senders of newsletters and mailing lists")
+	fs.StringVar(&o.GroupBy, "group-by", "sender", "prompt about each 'sender', or roll senders up by sending 'domain'")
+	fs.StringVar(&o.Sort, "sort", "count", "order to prompt about senders in: 'count', 'size', 'recent', 'unread' or 'score'")
+	fs.IntVar(&o.Top, "top", 0, "only prompt about the top N senders (0 for all senders)")
+	fs.IntVar(&o.StopBelow, "stop-below", 0, "stop prompting once senders have sent fewer than this many emails")
+	fs.BoolVar(&o.Verbose, "verbose", false, "log debugging detail")
+	fs.BoolVar(&o.Quiet, "quiet", false, "only log warnings and errors, and hide progress bars")
+	fs.StringVar(&o.DeletionLog, "log", "", "append a JSON line for every email moved to the Trash to this file (e.g. deletions.jsonl)")
+	fs.StringVar(&o.LogFile, "log-file", "", "write log messages to this file instead of stderr")
+	fs.BoolVar(&o.NoColor, "no-color", false, "do not colour the output")
+	fs.StringVar(&o.SnapshotPath, "snapshot", "", "file to save scan results to (default snapshot.json, or snapshot-<profile>.json)")
+	fs.BoolVar(&o.NoCache, "no-cache", false, "fetch every email again instead of reading emails seen by earlier scans from the cache")
+	fs.StringVar(&o.FromSnapshot, "from-snapshot", "", "load scan results from this snapshot file instead of scanning the mailbox")
+	fs.StringVar(&o.SQLite, "sqlite", "", "SQLite database file the export command writes every scanned email's metadata to")
+	fs.StringVar(&o.HTML, "html", "report.html", "file the report command writes its HTML report to")
+	fs.BoolVar(&o.Redact, "redact", false, "hide the local part of addresses and email subjects in the output, for sharing")
+	fs.BoolVar(&o.Notify, "notify", false, "show a desktop notification when the run finishes or fails")
+	fs.BoolVar(&o.Bell, "bell", false, "ring the terminal bell when the run finishes or fails")
+	fs.BoolVar(&o.Borders, "borders", false, "draw borders around tables")
+	fs.StringVar(&o.Stream, "stream", "", "set to 'jsonl' to stream each sender's statistics as JSON lines during the scan")
+	fs.StringVar(&o.LargerThan, "larger-than", "", "only include emails larger than this size (e.g. 10M); the sent command defaults to 1M, and the scan command lists individual emails when it is given")
+	fs.StringVar(&o.Answers, "answers", "", "file of sender=answer lines to answer the prompts from, or '-' to read them from stdin")
+	fs.DurationVar(&o.PromptTimeout, "prompt-timeout", 0, "answer prompts automatically if nothing is entered within this long (e.g. 30s)")
+	fs.StringVar(&o.DefaultAnswer, "default-answer", "no", "answer given to a sender prompt which times out, either 'no', 'protect' or 'quit'")
+	fs.IntVar(&o.Preview, "preview", 5, "show the subjects of this many recent emails before asking about a sender (0 to turn off)")
+	fs.IntVar(&o.PageSize, "page-size", 50, "show the ranked sender list this many senders at a time (0 to show them all at once)")
+	fs.IntVar(&o.MinCount, "min-count", 0, "group senders with fewer than this many emails as others, and never prompt about them")
+	fs.StringVar(&o.PurgeAfter, "purge-after", "", "permanently delete emails this tool trashed once they are older than this (e.g. 7d)")
+	fs.Func("scopes", "comma separated OAuth scopes to request", func(value string) error {
+		o.Scopes = splitList(value)
+		return nil
+	})
+	fs.Func("include-labels", "comma separated system labels to scan which are left out by default: "+strings.Join(scanExcludedLabels, ", "), func(value string) error {
+		for _, label := range splitList(value) {
+			if !slices.Contains(scanExcludedLabels, strings.ToLower(label)) {
+				return fmt.Errorf("unknown label %q, expected one of %s", label, strings.Join(scanExcludedLabels, ", "))
+			}
+		}
+		o.IncludeLabels = splitList(value)
+		return nil
+	})
+	fs.Func("protect", "comma separated senders which are never offered up for deletion", func(value string) error {
+		o.Protected = splitList(value)
+		return nil
+	})
+	return fs
+}
+
+// Split the arguments into a command and its positional arguments, and parse
+// the flags into opts. Flags may be given anywhere in the arguments.
+// Running with no command starts the interactive clean up
+func parseArgs(args []string) (string, []string, error) {
+	command := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	fs := newFlagSet(&opts)
+
+	// The flag package stops at the first positional argument, so keep
+	// parsing after each one to allow flags and arguments to be mixed
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return "", nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	// Fill in anything not given on the command line from the config file
+	config, err := loadConfig(opts.ConfigPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := applyConfig(config, fs); err != nil {
+		return "", nil, err
+	}
+
+	if opts.Output != "text" && opts.Output != "json" {
+		return "", nil, fmt.Errorf("unknown output format %q, expected 'text' or 'json'", opts.Output)
+	}
+	if opts.GroupBy != "sender" && opts.GroupBy != "domain" {
+		return "", nil, fmt.Errorf("unknown grouping %q, expected 'sender' or 'domain'", opts.GroupBy)
+	}
+	switch opts.Sort {
+	case "count", "size", "recent", "unread", "score":
+	default:
+		return "", nil, fmt.Errorf("unknown sort order %q, expected 'count', 'size', 'recent', 'unread' or 'score'", opts.Sort)
+	}
+	switch opts.DefaultAnswer {
+	case "no", "protect", "quit":
+	default:
+		return "", nil, fmt.Errorf("unknown default answer %q, expected 'no', 'protect' or 'quit'", opts.DefaultAnswer)
+	}
+	if opts.InactiveAfter != "0" {
+		if _, err := parseAge(opts.InactiveAfter); err != nil {
+			return "", nil, fmt.Errorf("invalid --inactive-after: %v", err)
+		}
+	}
+	if _, err := parseSize(opts.Quota); err != nil {
+		return "", nil, fmt.Errorf("invalid --quota: %v", err)
+	}
+	if opts.Permanent && opts.Threads {
+		return "", nil, fmt.Errorf("--permanent cannot be used with --threads")
+	}
+	if _, _, err := olderThanCutoff(time.Now()); err != nil {
+		return "", nil, err
+	}
+	if opts.OlderThan != "" && opts.Threads {
+		return "", nil, fmt.Errorf("--older-than cannot be used with --threads")
+	}
+	if opts.KeepLatest < 0 {
+		return "", nil, fmt.Errorf("--keep-latest cannot be negative")
+	}
+	if opts.KeepLatest > 0 && opts.Threads {
+		return "", nil, fmt.Errorf("--keep-latest cannot be used with --threads")
+	}
+	if opts.Stream != "" && opts.Stream != "jsonl" {
+		return "", nil, fmt.Errorf("unknown stream format %q, expected 'jsonl'", opts.Stream)
+	}
+
+	// A command may also come after the flags
+	if command == "" && len(positional) > 0 {
+		command, positional = positional[0], positional[1:]
+	}
+	return command, positional, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// Where human readable text (prompts, progress and summaries) is written.
+// In JSON output mode this is stderr, so that stdout only contains JSON
+var display io.Writer = os.Stdout
+
+// JSON record listing the statistics of every sender found by the scan
+type SenderStatsRecord struct {
+	Type    string        `json:"type"`
+	Senders []SenderStats `json:"senders"`
+}
+
+// JSON record describing the emails which are about to be deleted
+type DeletionPlanRecord struct {
+	Type   string   `json:"type"`
+	Sender string   `json:"sender"`
+	Count  int      `json:"count"`
+	Ids    []string `json:"ids"`
+}
+
+// JSON record describing the outcome of a deletion
+type DeletionResultRecord struct {
+	Type    string   `json:"type"`
+	Sender  string   `json:"sender"`
+	Deleted int      `json:"deleted"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// JSON lines record streamed during a scan with a sender's statistics so far.
+// Interim records are sent as the scan goes, without message IDs, and a
+// final record with the IDs is sent for every sender once the scan finishes
+type SenderRecord struct {
+	Type  string `json:"type"`
+	Final bool   `json:"final"`
+	SenderStats
+}
+
+// Set up where output is written for the chosen output format
+func setupOutput() {
+	if opts.Output == "json" || opts.Stream == "jsonl" {
+		display = os.Stderr
+	}
+}
+
+// Stream a sender's statistics as a JSON line, if --stream jsonl was given
+func streamSender(stats SenderStats, final bool) {
+	if opts.Stream != "jsonl" {
+		return
+	}
+	if !final {
+		stats.Ids = nil
+	}
+	emitJSON(SenderRecord{Type: "sender", Final: final, SenderStats: redactSender(stats)})
+}
+
+// Returns true if structured JSON should be written to stdout
+func jsonOutput() bool {
+	return opts.Output == "json"
+}
+
+// Write a record to stdout as a single line of JSON, so the
+// output can be piped into jq and other tooling
+func emitJSON(record any) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		logger.Error("Unable to encode JSON output", "err", err)
+		return
+	}
+	os.Stdout.Write(append(data, '\n'))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Fewest emails a sender must have sent before their sending pattern is judged
+const patternMinEmails = 4
+
+// How far an interval between emails may be from the usual interval and still count as on schedule
+const patternTolerance = 0.15
+
+// Regularity above which a sender is described as sending on a schedule
+const regularThreshold = 0.5
+
+// Work out how often a sender sends from the times their emails arrived: the
+// usual interval between emails, and the share of intervals which are close
+// to it. A regularity near 1 means the sender is perfectly periodic, which
+// is typical of automated digests
+func sendingPattern(arrivals []time.Time) (time.Duration, float64) {
+	if len(arrivals) < patternMinEmails {
+		return 0, 0
+	}
+	sorted := append([]time.Time(nil), arrivals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Before(sorted[j])
+	})
+
+	intervals := make([]time.Duration, 0, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		intervals = append(intervals, sorted[i].Sub(sorted[i-1]))
+	}
+	byLength := append([]time.Duration(nil), intervals...)
+	sort.Slice(byLength, func(i, j int) bool {
+		return byLength[i] < byLength[j]
+	})
+	period := byLength[len(byLength)/2]
+	if period <= 0 {
+		return 0, 0
+	}
+
+	onSchedule := 0
+	for _, interval := range intervals {
+		if diff := abs(interval - period); float64(diff) <= patternTolerance*float64(period) {
+			onSchedule++
+		}
+	}
+	return period, float64(onSchedule) / float64(len(intervals))
+}
+
+// Describe how regularly a sender sends, e.g. "every 7 days, on Mondays
+// around 09:00 (92% on schedule)", or "" if they do not send on a schedule.
+// The day and hour are only given if most of their email arrives in that hour
+func describePattern(sender SenderStats) string {
+	if sender.Period <= 0 || sender.Regularity < regularThreshold {
+		return ""
+	}
+	description := "every " + formatPeriod(sender.Period)
+	if peakShare(sender.Heatmap) >= regularThreshold {
+		day, hour := peakHour(sender.Heatmap)
+		if sender.Period >= 7*24*time.Hour {
+			description += fmt.Sprintf(", on %ss", day)
+		}
+		description += fmt.Sprintf(" around %02d:00", hour)
+	}
+	return fmt.Sprintf("%s (%s on schedule)", description, formatPercent(sender.Regularity))
+}
+
+// Returns the day of the week and hour in which most of a heatmap's emails arrived
+func peakHour(heatmap [7][24]int) (time.Weekday, int) {
+	peakDay, peakHour := 0, 0
+	for day := range heatmap {
+		for hour, count := range heatmap[day] {
+			if count > heatmap[peakDay][peakHour] {
+				peakDay, peakHour = day, hour
+			}
+		}
+	}
+	return time.Weekday(peakDay), peakHour
+}
+
+// Format an interval between emails in the largest whole unit which fits, e.g. "7 days" or "6 hours"
+func formatPeriod(period time.Duration) string {
+	switch {
+	case period >= 24*time.Hour:
+		days := int((period + 12*time.Hour) / (24 * time.Hour))
+		if days == 1 {
+			return "day"
+		}
+		return fmt.Sprintf("%d days", days)
+	case period >= time.Hour:
+		hours := int((period + 30*time.Minute) / time.Hour)
+		if hours == 1 {
+			return "hour"
+		}
+		return fmt.Sprintf("%d hours", hours)
+	default:
+		return period.Round(time.Minute).String()
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Whether the user has agreed this run to deleting emails permanently with --permanent
+var permanentConfirmed bool
+
+// Ask the user to confirm they want emails deleted permanently, the first time
+// anything is permanently deleted in a run. Returns false if they do not
+func confirmPermanent() bool {
+	if permanentConfirmed {
+		return true
+	}
+	fmt.Fprintf(display, "%s\n", colorize(colorRed, "Permanently deleted emails skip the Trash, so they cannot be recovered or undone."))
+	fmt.Fprintf(display, "Type 'permanently delete' to continue:\n")
+	response, _ := readPrompt("permanent")
+	permanentConfirmed = strings.ToLower(strings.TrimSpace(response)) == "permanently delete"
+	return permanentConfirmed
+}
+
+// Returns true if the command permanently deletes emails, so needs the full
+// https://mail.google.com/ scope, as the modify scope cannot delete messages
+func needsFullScope(command string, args []string) bool {
+	if opts.Permanent {
+		return true
+	}
+	return (command == "trash" || command == "spam") && len(args) > 0 && args[0] == "purge"
+}
+
+// Permanently delete the given emails in batches, returning the IDs of those
+// which were deleted. A batch which fails is counted as failed and skipped
+func deletePermanently(ctx context.Context, srv *gmail.Service, label string, ids []string) []string {
+	defer startWork()()
+	var deleted []string
+	progress := newProgress(label, int64(len(ids)))
+	for start := 0; start < len(ids); start += batchSize {
+		if interrupted() {
+			break
+		}
+		batch := ids[start:min(start+batchSize, len(ids))]
+		req := &gmail.BatchDeleteMessagesRequest{Ids: batch}
+		_, err := callWriteAPI(ctx, "messages.batchDelete", noResult(srv.Users.Messages.BatchDelete("me", req)))
+		progress.Add(len(batch))
+		if err != nil {
+			logger.Warn("Failed to permanently delete batch of messages", "count", len(batch), "err", err)
+			failedMessages += len(batch)
+			continue
+		}
+		deleted = append(deleted, batch...)
+	}
+	progress.Finish()
+	return deleted
+}
+
+// Permanently delete one email, bypassing the Trash
+func deleteMessage(ctx context.Context, srv *gmail.Service, id string) error {
+	if _, err := callWriteAPI(ctx, "messages.delete", noResult(srv.Users.Messages.Delete("me", id))); err != nil {
+		return fmt.Errorf("failed to permanently delete message %s: %w", id, err)
+	}
+	return nil
+}
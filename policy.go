@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Layout of absolute dates accepted by --older-than
+const olderThanDateLayout = "2006-01-02"
+
+// Returns the time before which emails may be deleted with --older-than, or
+// false if it is not set. The option is either an age such as "1y", or a
+// date such as "2023-01-01"
+func olderThanCutoff(now time.Time) (time.Time, bool, error) {
+	if opts.OlderThan == "" {
+		return time.Time{}, false, nil
+	}
+	if date, err := time.ParseInLocation(olderThanDateLayout, opts.OlderThan, now.Location()); err == nil {
+		return date, true, nil
+	}
+	age, err := parseAge(opts.OlderThan)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid --older-than %q, expected an age such as 1y or a date such as 2023-01-01", opts.OlderThan)
+	}
+	return now.Add(-age), true, nil
+}
+
+// Narrow a sender's emails down to those the deletion options allow to be
+// deleted, returning the narrowed statistics and a description of each
+// group of emails kept back, e.g. "12 emails from after 1 Jan 2024"
+func deletableEmails(sender SenderStats, now time.Time) (SenderStats, []string) {
+	var reasons []string
+	kept := make(map[string]bool)
+
+	// Emails with no known date are kept by the date based options, as they may be recent
+	undated := 0
+	if opts.OlderThan != "" || opts.KeepLatest > 0 {
+		for _, id := range sender.Ids {
+			if _, known := sender.MessageTimes[id]; !known {
+				kept[id] = true
+				undated++
+			}
+		}
+	}
+
+	// With --keep-latest, the sender's most recent emails are kept
+	if opts.KeepLatest > 0 {
+		var dated []string
+		for _, id := range sender.Ids {
+			if !kept[id] {
+				dated = append(dated, id)
+			}
+		}
+		sort.SliceStable(dated, func(i, j int) bool {
+			return sender.MessageTimes[dated[i]] > sender.MessageTimes[dated[j]]
+		})
+		latest := dated[:min(opts.KeepLatest, len(dated))]
+		for _, id := range latest {
+			kept[id] = true
+		}
+		if len(latest) > 0 {
+			reasons = append(reasons, fmt.Sprintf("the latest %d emails", len(latest)))
+		}
+	}
+
+	// With --older-than, emails from after the cutoff are kept
+	if cutoff, ok, _ := olderThanCutoff(now); ok {
+		recent := 0
+		for _, id := range sender.Ids {
+			if !kept[id] && !time.Unix(sender.MessageTimes[id], 0).Before(cutoff) {
+				kept[id] = true
+				recent++
+			}
+		}
+		if recent > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d emails from after %s", recent, formatDate(cutoff)))
+		}
+	}
+
+	if undated > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d emails with no known date", undated))
+	}
+	return withoutEmails(sender, kept), reasons
+}
+
+// Returns the total size of the given emails from a sender. Snapshots saved
+// before each email's size was recorded only have the sender's total, so the
+// size is estimated in proportion to the number of emails instead
+func emailsSize(sender SenderStats, ids []string) int64 {
+	var total int64
+	for _, id := range ids {
+		size, ok := sender.MessageSizes[id]
+		if !ok {
+			return sender.Size * int64(len(ids)) / int64(max(sender.Count, 1))
+		}
+		total += size
+	}
+	return total
+}
+
+// Returns a copy of the sender's statistics without the given emails
+func withoutEmails(sender SenderStats, kept map[string]bool) SenderStats {
+	if len(kept) == 0 {
+		return sender
+	}
+	ids := make([]string, 0, len(sender.Ids))
+	for _, id := range sender.Ids {
+		if !kept[id] {
+			ids = append(ids, id)
+		}
+	}
+	sender.Size = emailsSize(sender, ids)
+	sender.Ids = ids
+	sender.Count = len(ids)
+	return sender
+}
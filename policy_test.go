@@ -0,0 +1,76 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDeletableEmails(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	sender := SenderStats{
+		Email: "news@store.com",
+		Count: 4,
+		Size:  400,
+		Ids:   []string{"new", "middle", "old", "undated"},
+		MessageTimes: map[string]int64{
+			"new":    time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC).Unix(),
+			"middle": time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC).Unix(),
+			"old":    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+		},
+		MessageSizes: map[string]int64{"new": 100, "middle": 100, "old": 100, "undated": 100},
+	}
+
+	tests := []struct {
+		name      string
+		options   Options
+		want      []string
+		wantKept  int
+		wantError bool
+	}{
+		{"no options", Options{}, []string{"new", "middle", "old", "undated"}, 0, false},
+		{"older than an age", Options{OlderThan: "1y"}, []string{"middle", "old"}, 2, false},
+		{"older than a date", Options{OlderThan: "2023-03-01"}, []string{"old"}, 2, false},
+		{"keep latest", Options{KeepLatest: 1}, []string{"middle", "old"}, 2, false},
+		{"keep latest and older than", Options{KeepLatest: 1, OlderThan: "2023-03-01"}, []string{"old"}, 3, false},
+		{"keep more than there are", Options{KeepLatest: 10}, []string{}, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOptions(t, tt.options)
+			got, reasons := deletableEmails(sender, now)
+			if !slices.Equal(got.Ids, tt.want) {
+				t.Errorf("deletable emails = %v, want %v", got.Ids, tt.want)
+			}
+			if got.Count != len(tt.want) || got.Size != int64(100*len(tt.want)) {
+				t.Errorf("deletable count and size = %d, %d, want %d, %d", got.Count, got.Size, len(tt.want), 100*len(tt.want))
+			}
+			if len(reasons) != tt.wantKept {
+				t.Errorf("reasons = %q, want %d of them", reasons, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestOlderThanCutoff(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		olderThan string
+		want      time.Time
+		wantSet   bool
+		wantErr   bool
+	}{
+		{"", time.Time{}, false, false},
+		{"30d", now.AddDate(0, 0, -30), true, false},
+		{"2023-01-01", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), true, false},
+		{"2023-13-01", time.Time{}, false, true},
+		{"soon", time.Time{}, false, true},
+	}
+	for _, tt := range tests {
+		setOptions(t, Options{OlderThan: tt.olderThan})
+		got, set, err := olderThanCutoff(now)
+		if (err != nil) != tt.wantErr || set != tt.wantSet || !got.Equal(tt.want) {
+			t.Errorf("olderThanCutoff() with %q = %v, %t, %v, want %v, %t, error %t", tt.olderThan, got, set, err, tt.want, tt.wantSet, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Print the subjects and dates of the sender's most recent emails, so the
+// user can see what kind of mail it is before deciding
+func previewSender(ctx context.Context, srv *gmail.Service, sender SenderStats) {
+	if opts.Preview <= 0 || len(sender.Ids) == 0 {
+		return
+	}
+
+	defer startWork()()
+	table := newTable("Date", "Subject")
+	for _, id := range latestIds(sender, opts.Preview) {
+		if interrupted() {
+			break
+		}
+		message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders("Subject", "Date"))
+		if err != nil {
+			logger.Warn("Could not get email metadata for preview", "id", id, "err", err)
+			continue
+		}
+		subject := messageHeaders(message)["subject"]
+		if subject == "" {
+			subject = "(no subject)"
+		}
+		table.AddRow(formatDate(messageTime(message)), redactSubject(subject))
+	}
+	fmt.Fprintf(display, "Most recent emails:\n")
+	table.Render(display)
+}
+
+// Returns up to n of the sender's most recent email IDs, newest first. The
+// scan lists each address's emails newest first, but merged senders and
+// domain groups hold one address's emails after another, so the IDs are
+// sorted by when each email arrived. Emails with no known date come last
+func latestIds(sender SenderStats, n int) []string {
+	ids := append([]string(nil), sender.Ids...)
+	sort.SliceStable(ids, func(i, j int) bool {
+		ti, iKnown := sender.MessageTimes[ids[i]]
+		tj, jKnown := sender.MessageTimes[ids[j]]
+		if iKnown != jKnown {
+			return iKnown
+		}
+		return ti > tj
+	})
+	return ids[:min(len(ids), n)]
+}
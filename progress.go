@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Width of the bar drawn by the progress display, in characters
+const progressBarWidth = 30
+
+// Tracks how far through a long running operation we are, and draws a
+// single updating progress line in the terminal
+type Progress struct {
+	label    string
+	total    int64
+	done     int64
+	pages    int
+	start    time.Time
+	lastDraw time.Time
+	out      io.Writer
+}
+
+// Create a progress display for an operation with the given number of items.
+// A total of 0 means the total is unknown, in which case no ETA is shown
+func newProgress(label string, total int64) *Progress {
+	return &Progress{
+		label: label,
+		total: total,
+		start: time.Now(),
+		out:   progressOutput(),
+	}
+}
+
+// Record that n more items have been processed
+func (p *Progress) Add(n int) {
+	p.done += int64(n)
+	p.draw(false)
+}
+
+// Record that another page of results has been fetched
+func (p *Progress) Page() {
+	p.pages++
+	p.draw(false)
+}
+
+// Draw the final state of the progress line and move onto a new line
+func (p *Progress) Finish() {
+	p.draw(true)
+	fmt.Fprintf(p.out, "\n")
+}
+
+// Redraw the progress line. Redraws are throttled so printing does not
+// slow down the operation being tracked, unless force is set
+func (p *Progress) draw(force bool) {
+	now := time.Now()
+	if !force && now.Sub(p.lastDraw) < 200*time.Millisecond {
+		return
+	}
+	p.lastDraw = now
+
+	elapsed := now.Sub(p.start)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed.Seconds()
+	}
+
+	// The total is only an estimate, so never let the bar overflow
+	fraction := 0.0
+	if p.total > 0 {
+		fraction = min(float64(p.done)/float64(p.total), 1)
+	}
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	eta := "--"
+	if p.total > 0 && rate > 0 && p.done < p.total {
+		remaining := time.Duration(float64(p.total-p.done)/rate) * time.Second
+		eta = remaining.Round(time.Second).String()
+	} else if p.total > 0 && p.done >= p.total {
+		eta = "0s"
+	}
+
+	// Operations which do not fetch pages of results leave the page count out
+	pages := ""
+	if p.pages > 0 {
+		pages = fmt.Sprintf(" %d pages,", p.pages)
+	}
+
+	fmt.Fprintf(p.out, "\r%s [%s] %3.0f%% %d/%d messages,%s %.1f msg/s, elapsed %s, ETA %s, %d quota units   ",
+		p.label, bar, fraction*100, p.done, p.total, pages, rate, elapsed.Round(time.Second), eta, quotaUnits)
+}
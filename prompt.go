@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reader for answers typed at prompts
+var stdin = bufio.NewReader(os.Stdin)
+
+// Lines read from stdin in the background, used when prompts have a timeout.
+// The channel is closed once there is no more input
+var (
+	inputLines  chan string
+	startReader sync.Once
+)
+
+// Read a line of input from the user, with surrounding whitespace removed.
+// Returns false once there is no more input to read. If the prompt times out,
+// an empty line is returned, which every prompt treats as skipping
+func readLine() (string, bool) {
+	return readAnswer("")
+}
+
+// Read a line of input from the user like readLine, but return the given
+// answer instead if nothing is entered within --prompt-timeout
+func readAnswer(fallback string) (string, bool) {
+	if opts.PromptTimeout <= 0 {
+		return readStdin()
+	}
+
+	// Once a prompt has timed out its line may still arrive later, so all
+	// input goes through one background reader rather than each prompt
+	startReader.Do(func() {
+		inputLines = make(chan string)
+		go func() {
+			for {
+				line, ok := readStdin()
+				if !ok {
+					close(inputLines)
+					return
+				}
+				inputLines <- line
+			}
+		}()
+	})
+
+	select {
+	case line, ok := <-inputLines:
+		return line, ok
+	case <-time.After(opts.PromptTimeout):
+		fmt.Fprintf(display, "No answer after %s, using %q\n", opts.PromptTimeout, fallback)
+		return fallback, true
+	}
+}
+
+// Read a line from stdin, with surrounding whitespace removed
+func readStdin() (string, bool) {
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
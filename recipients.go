@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returns how the account received an email from its To and Cc headers:
+// "direct" if it was in the To header, "cc" if it was only copied in, or ""
+// if neither, e.g. it was Bcc'd or sent to a mailing list. Returns "" if the
+// account's address is not known
+func recipientKind(headers map[string]string) string {
+	if accountEmail == "" {
+		return ""
+	}
+	account := canonicalAddress(accountEmail)
+	for _, address := range extractAddresses(headers["to"]) {
+		if canonicalAddress(address) == account {
+			return "direct"
+		}
+	}
+	for _, address := range extractAddresses(headers["cc"]) {
+		if canonicalAddress(address) == account {
+			return "cc"
+		}
+	}
+	return ""
+}
+
+// Returns true if the sender has copied the account in on emails but never
+// sent one straight to it. Email the user was only copied in on is usually
+// safe to delete
+func isCCOnly(sender SenderStats) bool {
+	return sender.CC > 0 && sender.Direct == 0
+}
+
+// Describe the share of a sender's emails which were sent straight to the
+// account, or "-" if none were addressed to it either way
+func describeDirect(sender SenderStats) string {
+	if sender.Direct+sender.CC == 0 {
+		return "-"
+	}
+	return formatPercent(float64(sender.Direct) / float64(sender.Count))
+}
+
+// Describe how a sender's emails were addressed to the account, e.g. "12 to you, 30 cc'd"
+func describeRecipients(sender SenderStats) string {
+	var parts []string
+	if sender.Direct > 0 {
+		parts = append(parts, fmt.Sprintf("%d to you", sender.Direct))
+	}
+	if sender.CC > 0 {
+		parts = append(parts, fmt.Sprintf("%d cc'd", sender.CC))
+	}
+	if other := sender.Count - sender.Direct - sender.CC; other > 0 && len(parts) > 0 {
+		parts = append(parts, fmt.Sprintf("%d other", other))
+	}
+	return strings.Join(parts, ", ")
+}
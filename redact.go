@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+)
+
+// Log attributes which hold email addresses, and are redacted by --redact
+var redactedLogKeys = map[string]bool{
+	"sender":    true,
+	"email":     true,
+	"recipient": true,
+	"account":   true,
+}
+
+// Log attributes which may contain email addresses among other text, such as
+// Gmail searches and rules. Only the addresses in them are redacted
+var redactedTextLogKeys = map[string]bool{
+	"query": true,
+	"rule":  true,
+}
+
+// Matches the email addresses in a search or rule
+var addressPattern = regexp.MustCompile(`[^\s@"'<>(){}:,]+@[^\s@"'<>(){}:,]+`)
+
+// Key for the pseudonyms, chosen at random for each run. Without it, anyone
+// with redacted output could find an address by hashing likely addresses
+var redactKey = newRedactKey()
+
+// Returns a new random key for the pseudonyms
+func newRedactKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Returns a pseudonym for the value, which is the same for the same value
+// throughout the run but different in every run
+func pseudonym(value string) string {
+	mac := hmac.New(sha256.New, redactKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Returns the address with its local part replaced by a pseudonym if --redact
+// was given, e.g. "sender-1a2b3c4d5e6f7a8b@github.com". The same address gets
+// the same pseudonym throughout a run, so senders can still be told apart in shared output
+func redactAddress(email string) string {
+	if !opts.Redact {
+		return email
+	}
+	local, domain := splitAddress(email)
+	if domain == "" {
+		return email
+	}
+	return "sender-" + pseudonym(local+"@"+domain) + "@" + domain
+}
+
+// Returns the mailing list identifier replaced by a pseudonym if --redact was
+// given, as it often names the subscriber or their account
+func redactListID(listID string) string {
+	if !opts.Redact || listID == "" {
+		return listID
+	}
+	return "list-" + pseudonym(listID)
+}
+
+// Returns the text with every email address in it redacted
+func redactAddresses(text string) string {
+	if !opts.Redact {
+		return text
+	}
+	return addressPattern.ReplaceAllStringFunc(text, redactAddress)
+}
+
+// Returns the subject, or a placeholder if --redact was given
+func redactSubject(subject string) string {
+	if !opts.Redact {
+		return subject
+	}
+	return "(subject hidden)"
+}
+
+// Returns a copy of the sender statistics with the addresses redacted, for reports
+func redactStats(senderStats []SenderStats) []SenderStats {
+	if !opts.Redact {
+		return senderStats
+	}
+	redacted := make([]SenderStats, len(senderStats))
+	for i, sender := range senderStats {
+		redacted[i] = redactSender(sender)
+	}
+	return redacted
+}
+
+// Returns a copy of one sender's statistics with the addresses redacted and names and subjects left out
+func redactSender(sender SenderStats) SenderStats {
+	sender.Email = redactAddress(sender.Email)
+	sender.Subjects = nil
+	sender.DisplayName = ""
+	sender.ListID = redactListID(sender.ListID)
+	if len(sender.Aliases) > 0 {
+		aliases := make(map[string]int)
+		for address, count := range sender.Aliases {
+			aliases[redactAddress(address)] = count
+		}
+		sender.Aliases = aliases
+	}
+	if len(sender.ForwardedFrom) > 0 {
+		forwarded := make(map[string]int)
+		for account, count := range sender.ForwardedFrom {
+			forwarded[redactAddress(account)] = count
+		}
+		sender.ForwardedFrom = forwarded
+	}
+	return sender
+}
+
+// Redact the addresses in log attributes, for use as a slog ReplaceAttr function
+func redactLogAttr(groups []string, attr slog.Attr) slog.Attr {
+	if redactedLogKeys[attr.Key] && attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, redactAddress(attr.Value.String()))
+	}
+	if redactedTextLogKeys[attr.Key] && attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, redactAddresses(attr.Value.String()))
+	}
+	return attr
+}
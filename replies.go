@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Count how many emails the user has sent to each sender, by reading the
+// recipients of everything in the Sent folder. Senders who have never been
+// written to are much safer to delete in bulk than people the user talks to
+func countReplies(ctx context.Context, srv *gmail.Service, senderMap map[string]*SenderStats) error {
+	ids, err := listMessageIds(ctx, srv, "in:sent")
+	if err != nil {
+		return err
+	}
+
+	progress := newProgress("Checking replies", int64(len(ids)))
+	sent := make(map[string]int)
+	for _, id := range ids {
+		if interrupted() {
+			return errInterrupted
+		}
+		message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders("To", "Cc", "Bcc"))
+		progress.Add(1)
+		if err != nil {
+			logger.Warn("Could not get sent email metadata, continuing", "id", id, "err", err)
+			continue
+		}
+		for _, values := range [][]string{headerValues(message, "To"), headerValues(message, "Cc"), headerValues(message, "Bcc")} {
+			for _, value := range values {
+				for _, address := range extractAddresses(value) {
+					sent[strings.ToLower(address)]++
+				}
+			}
+		}
+	}
+	progress.Finish()
+
+	for email, stats := range senderMap {
+		stats.Replies = sent[strings.ToLower(email)]
+		stats.RepliesChecked = true
+	}
+	return nil
+}
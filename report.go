@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"time"
+)
+
+// Number of months of history drawn in the report's volume chart
+const reportMonths = 24
+
+// Number of senders listed in the report
+const reportSenders = 50
+
+// Height of the bars in the report's volume chart, in pixels
+const reportChartHeight = 160
+
+// One bar of the volume chart in the HTML report
+type ReportBar struct {
+	Month  string
+	Count  int
+	Bytes  string
+	X      int
+	Y      int
+	Height int
+}
+
+// Everything shown in the HTML report
+type ReportData struct {
+	CreatedAt    string
+	Profile      string
+	TotalEmails  int
+	TotalSenders int
+	Used         string
+	Quota        string
+	Growth       string
+	QuotaReached string
+	Bars         []ReportBar
+	FirstMonth   string
+	LastMonth    string
+	ChartWidth   int
+	ChartHeight  int
+	Senders      []ReportSender
+}
+
+// One row of the sender table in the HTML report
+type ReportSender struct {
+	Rank       int
+	Email      string
+	Count      int
+	Size       string
+	Unread     string
+	LastSeen   string
+	Trend      string
+	Pattern    string
+	Newsletter bool
+}
+
+// Page layout of the HTML report. Everything is inline, so the file can be
+// opened or shared on its own
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Mailbox report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: left; }
+td.num { text-align: right; }
+rect { fill: #4a7bd0; }
+.summary td { border: none; }
+td.trend { font-family: monospace; white-space: pre; }
+</style>
+</head>
+<body>
+<h1>Mailbox report</h1>
+<p>Created {{.CreatedAt}} for profile {{.Profile}}.</p>
+
+<h2>Storage</h2>
+<table class="summary">
+<tr><td>Emails scanned</td><td>{{.TotalEmails}} from {{.TotalSenders}} senders</td></tr>
+<tr><td>Mail storage used</td><td>{{.Used}} of {{.Quota}}</td></tr>
+<tr><td>Average growth</td><td>{{.Growth}} per month</td></tr>
+<tr><td>Quota will be reached</td><td>{{.QuotaReached}}</td></tr>
+</table>
+
+<h2>Emails received per month</h2>
+<svg width="{{.ChartWidth}}" height="{{.ChartHeight}}">
+{{range .Bars}}<rect x="{{.X}}" y="{{.Y}}" width="20" height="{{.Height}}"><title>{{.Month}}: {{.Count}} emails, {{.Bytes}}</title></rect>
+{{end}}</svg>
+<p>{{.FirstMonth}} to {{.LastMonth}}. Hover over a bar for the month's totals.</p>
+
+<h2>Top senders</h2>
+<table>
+<tr><th>#</th><th>Sender</th><th>Emails</th><th>Size</th><th>Unread</th><th>Last email</th><th>Last 12 months</th><th>Sends</th><th>Newsletter</th></tr>
+{{range .Senders}}<tr><td class="num">{{.Rank}}</td><td>{{.Email}}</td><td class="num">{{.Count}}</td><td class="num">{{.Size}}</td><td class="num">{{.Unread}}</td><td>{{.LastSeen}}</td><td class="trend">{{.Trend}}</td><td>{{.Pattern}}</td><td>{{if .Newsletter}}yes{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// Handles the 'report' command, which writes the scan as a standalone HTML
+// page with the top senders, a chart of volume over time and a storage forecast
+func runReportCommand(senderStats []SenderStats, now time.Time) error {
+	data, err := reportData(redactStats(senderStats), now)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(opts.HTML)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := reportTemplate.Execute(file, data); err != nil {
+		return err
+	}
+	fmt.Fprintf(display, "Wrote the report to %s\n", opts.HTML)
+	return nil
+}
+
+// Gather everything shown in the report from the scan
+func reportData(senderStats []SenderStats, now time.Time) (ReportData, error) {
+	quota, err := parseSize(opts.Quota)
+	if err != nil {
+		return ReportData{}, err
+	}
+
+	data := ReportData{
+		CreatedAt:    now.Format("2 January 2006 15:04"),
+		Profile:      opts.Profile,
+		TotalSenders: len(senderStats),
+		Quota:        formatSize(quota),
+	}
+
+	// Work out the totals and the storage forecast, as the forecast command does
+	var used int64
+	monthlyBytes := make(map[string]int64)
+	monthlyCount := make(map[string]int)
+	for _, sender := range senderStats {
+		data.TotalEmails += sender.Count
+		used += sender.Size
+		for month, bytes := range sender.MonthlyBytes {
+			monthlyBytes[month] += bytes
+		}
+		for month, count := range sender.MonthlyCount {
+			monthlyCount[month] += count
+		}
+	}
+	growth := monthlyGrowth(monthlyBytes, now)
+	data.Used = formatSize(used)
+	data.Growth = formatSize(growth)
+	data.QuotaReached = describeMonths(monthsUntilFull(max(quota-used, 0), growth), now)
+
+	// Draw one bar per month, scaled to the busiest month
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	peak := 1
+	for i := 0; i < reportMonths; i++ {
+		peak = max(peak, monthlyCount[firstOfMonth.AddDate(0, -i, 0).Format("2006-01")])
+	}
+	for i := reportMonths - 1; i >= 0; i-- {
+		month := firstOfMonth.AddDate(0, -i, 0).Format("2006-01")
+		height := monthlyCount[month] * reportChartHeight / peak
+		data.Bars = append(data.Bars, ReportBar{
+			Month:  month,
+			Count:  monthlyCount[month],
+			Bytes:  formatSize(monthlyBytes[month]),
+			X:      len(data.Bars) * 24,
+			Y:      reportChartHeight - height,
+			Height: height,
+		})
+	}
+	data.FirstMonth = data.Bars[0].Month
+	data.LastMonth = data.Bars[len(data.Bars)-1].Month
+	data.ChartWidth = reportMonths * 24
+	data.ChartHeight = reportChartHeight
+
+	// List the biggest senders, in the same order as the prompts
+	sorted := append([]SenderStats(nil), senderStats...)
+	sortSenders(sorted, opts.Sort)
+	for i, sender := range sorted[:min(len(sorted), reportSenders)] {
+		data.Senders = append(data.Senders, ReportSender{
+			Rank:       i + 1,
+			Email:      sender.Email,
+			Count:      sender.Count,
+			Size:       formatSize(sender.Size),
+			Unread:     formatPercent(unreadRatio(sender)),
+			LastSeen:   formatDate(sender.LastSeen),
+			Trend:      sparkline(sender.MonthlyCount, now),
+			Pattern:    describePattern(sender),
+			Newsletter: sender.Newsletter,
+		})
+	}
+	return data, nil
+}
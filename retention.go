@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Age buckets used in the label retention report, as the age in days each one ends at
+var retentionBuckets = []struct {
+	name string
+	days int
+}{
+	{"0-30d", 30},
+	{"30-90d", 90},
+	{"90d-1y", 365},
+	{">1y", 0},
+}
+
+// Returns the index of the retention bucket an email received at the given time falls in
+func retentionBucket(received, now time.Time) int {
+	days := int(now.Sub(received).Hours() / 24)
+	for i, bucket := range retentionBuckets[:len(retentionBuckets)-1] {
+		if days < bucket.days {
+			return i
+		}
+	}
+	return len(retentionBuckets) - 1
+}
+
+// Handles the 'labels' command, which shows how old the emails carrying each
+// label are, so it is clear where old mail builds up and which labels
+// would benefit from a retention rule
+func runLabelsCommand(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) {
+	ages := make(map[string][]int)
+	for _, sender := range senderStats {
+		for label, counts := range sender.LabelAges {
+			if ages[label] == nil {
+				ages[label] = make([]int, len(retentionBuckets))
+			}
+			for i, count := range counts {
+				ages[label][i] += count
+			}
+		}
+	}
+
+	labels := make([]string, 0, len(ages))
+	totals := make(map[string]int)
+	for label, counts := range ages {
+		labels = append(labels, label)
+		for _, count := range counts {
+			totals[label] += count
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if totals[labels[i]] != totals[labels[j]] {
+			return totals[labels[i]] > totals[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+
+	headers := []string{"Label", "Emails"}
+	for _, bucket := range retentionBuckets {
+		headers = append(headers, bucket.name)
+	}
+	table := newTable(headers...).AlignRight(1, 2, 3, 4, 5)
+	for _, label := range labels {
+		row := []string{labelName(ctx, srv, label), strconv.Itoa(totals[label])}
+		for _, count := range ages[label] {
+			row = append(row, strconv.Itoa(count))
+		}
+		table.AddRow(row...)
+	}
+	fmt.Fprintf(display, "\nEmails carrying each label, by age:\n")
+	table.Render(display)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// Maximum number of message IDs the Gmail API accepts in one batch request
+const batchSize = 1000
+
+// A saved rule which is applied automatically at the start of every run.
+// It matches emails from Sender, optionally only those whose subject contains
+// Subject and which are older than OlderThan. Matching emails have Action
+// (trash or archive) applied to them, and AddLabels and RemoveLabels added
+// and removed. Missing labels in AddLabels are created
+type Rule struct {
+	Sender       string   `yaml:"sender"`
+	Subject      string   `yaml:"subject,omitempty"`
+	OlderThan    string   `yaml:"older_than,omitempty"`
+	Action       string   `yaml:"action,omitempty"`
+	AddLabels    []string `yaml:"add_labels,omitempty"`
+	RemoveLabels []string `yaml:"remove_labels,omitempty"`
+}
+
+// Describe the rule in the same syntax used to create it at the prompt
+func (r Rule) String() string {
+	parts := []string{"from:" + r.Sender}
+	if r.Subject != "" {
+		parts = append(parts, `subject~"`+quotedPhrase(r.Subject)+`"`)
+	}
+	if r.OlderThan != "" {
+		parts = append(parts, "older:"+r.OlderThan)
+	}
+	for _, label := range r.AddLabels {
+		parts = append(parts, "+label:"+label)
+	}
+	for _, label := range r.RemoveLabels {
+		parts = append(parts, "-label:"+label)
+	}
+	if r.Action != "" {
+		parts = append(parts, r.Action)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Returns the Gmail search query which finds the emails the rule applies to
+func (r Rule) Query() string {
+	parts := []string{"from:" + r.Sender}
+	if r.Subject != "" {
+		parts = append(parts, `subject:"`+quotedPhrase(r.Subject)+`"`)
+	}
+	if r.OlderThan != "" {
+		// Rules are validated when they are created, so the age is known to parse
+		age, _ := parseAge(r.OlderThan)
+		parts = append(parts, fmt.Sprintf("older_than:%dd", int(age.Hours()/24)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Returns the text to put inside a quoted phrase in a Gmail search. Gmail has
+// no escape sequences, so any quotes in the text are removed rather than
+// escaped, as an escaped quote would end the phrase early
+func quotedPhrase(text string) string {
+	return strings.ReplaceAll(text, `"`, "")
+}
+
+// Returns the path of the selected profile's rules file, which lives next to
+// the config file. Each profile has its own rules, as they name its senders
+func rulesPath() string {
+	name := profileFile("rules", ".yaml")
+	if opts.ConfigPath == "" {
+		return name
+	}
+	return filepath.Join(filepath.Dir(opts.ConfigPath), name)
+}
+
+// Read the saved rules. A missing rules file just means there are no rules
+func loadRules() ([]Rule, error) {
+	data, err := os.ReadFile(rulesPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %v", rulesPath(), err)
+	}
+	return rules, nil
+}
+
+// Add a rule to the rules file
+func addRule(rule Rule) error {
+	rules, err := loadRules()
+	if err != nil {
+		return err
+	}
+	rules = append(rules, rule)
+
+	data, err := yaml.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rulesPath()), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(rulesPath(), data, 0600)
+}
+
+// Split a rule into space separated terms, keeping quoted text together
+func splitTerms(text string) ([]string, error) {
+	var terms []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ' ' && !inQuotes:
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+	return terms, nil
+}
+
+// Parse a rule typed at the prompt, e.g. 'rule: subject~"digest" older:30d trash'
+// or 'rule: +label:Newsletters -label:INBOX -label:UNREAD', into a rule for the given sender
+func parseRule(answer, sender string) (Rule, error) {
+	rule := Rule{Sender: sender}
+	text := strings.TrimSpace(answer[len("rule:"):])
+
+	terms, err := splitTerms(text)
+	if err != nil {
+		return rule, err
+	}
+
+	for _, term := range terms {
+		switch {
+		case strings.HasPrefix(term, "subject~"):
+			rule.Subject = strings.TrimPrefix(term, "subject~")
+		case strings.HasPrefix(term, "older:"):
+			rule.OlderThan = strings.TrimPrefix(term, "older:")
+			if _, err := parseAge(rule.OlderThan); err != nil {
+				return rule, err
+			}
+		case strings.HasPrefix(term, "+label:"):
+			rule.AddLabels = append(rule.AddLabels, strings.TrimPrefix(term, "+label:"))
+		case strings.HasPrefix(term, "-label:"):
+			rule.RemoveLabels = append(rule.RemoveLabels, strings.TrimPrefix(term, "-label:"))
+		case term == "trash" || term == "archive":
+			rule.Action = term
+		default:
+			return rule, fmt.Errorf("unknown term %q", term)
+		}
+	}
+
+	if rule.Action == "" && len(rule.AddLabels) == 0 && len(rule.RemoveLabels) == 0 {
+		return rule, fmt.Errorf("no action given, expected 'trash', 'archive', '+label:<name>' or '-label:<name>'")
+	}
+	return rule, nil
+}
+
+// Apply every saved rule
+func applyRules(ctx context.Context, srv *gmail.Service) {
+	rules, err := loadRules()
+	if err != nil {
+		logger.Error("Unable to load rules", "err", err)
+		return
+	}
+	for _, rule := range rules {
+		applyRule(ctx, srv, rule)
+	}
+}
+
+// Find the emails a rule matches and apply its action to them
+func applyRule(ctx context.Context, srv *gmail.Service, rule Rule) {
+	ids, err := listMessageIds(ctx, srv, rule.Query())
+	if err != nil {
+		logger.Error("Unable to find emails matching rule", "rule", rule.String(), "err", err)
+		return
+	}
+	if len(ids) == 0 {
+		logger.Info("No emails match rule", "rule", rule.String())
+		return
+	}
+
+	logger.Info("Applying rule", "rule", rule.String(), "count", len(ids))
+	// Rules run before anything is asked, so they always move emails to the
+	// Trash, even with --permanent, where they can be restored from
+	if rule.Action == "trash" {
+		if _, err := removeEmails(ctx, srv, rule.Sender, ids, false); err != nil {
+			logger.Error("Rule deletions failed", "rule", rule.String(), "err", err)
+		}
+		return
+	}
+
+	// Everything else is a change of labels, which can be done in one batch request.
+	// Archiving is the same as removing the INBOX label
+	add, err := labelIDList(ctx, srv, rule.AddLabels, true)
+	if err != nil {
+		logger.Error("Unable to find rule labels", "rule", rule.String(), "err", err)
+		return
+	}
+	remove, err := labelIDList(ctx, srv, rule.RemoveLabels, false)
+	if err != nil {
+		logger.Error("Unable to find rule labels", "rule", rule.String(), "err", err)
+		return
+	}
+	action := "label"
+	if rule.Action == "archive" {
+		remove = append(remove, "INBOX")
+		action = "archive"
+	}
+	if err := modifyEmails(ctx, srv, rule.Sender, ids, add, remove, action); err != nil {
+		logger.Error("Rule label changes failed", "rule", rule.String(), "err", err)
+	}
+}
+
+// Add and remove labels on the given emails in batches, recording
+// the change in the journal under the given action name
+func modifyEmails(ctx context.Context, srv *gmail.Service, sender string, ids []string, add, remove []string, action string) error {
+	defer startWork()()
+	for start := 0; start < len(ids); start += batchSize {
+		if interrupted() {
+			return errInterrupted
+		}
+		batch := ids[start:min(start+batchSize, len(ids))]
+		req := &gmail.BatchModifyMessagesRequest{
+			Ids:            batch,
+			AddLabelIds:    add,
+			RemoveLabelIds: remove,
+		}
+		if _, err := callWriteAPI(ctx, "messages.batchModify", noResult(srv.Users.Messages.BatchModify("me", req))); err != nil {
+			return err
+		}
+		appendJournal(action, sender, batch)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	const sender = "news@store.com"
+	tests := []struct {
+		answer  string
+		want    Rule
+		wantErr bool
+	}{
+		{`rule: subject~"digest" older:30d trash`, Rule{Sender: sender, Subject: "digest", OlderThan: "30d", Action: "trash"}, false},
+		{`rule: subject~"weekly deals" archive`, Rule{Sender: sender, Subject: "weekly deals", Action: "archive"}, false},
+		{`rule: +label:Newsletters -label:INBOX -label:UNREAD`, Rule{Sender: sender, AddLabels: []string{"Newsletters"}, RemoveLabels: []string{"INBOX", "UNREAD"}}, false},
+		{`rule:   older:1y   trash  `, Rule{Sender: sender, OlderThan: "1y", Action: "trash"}, false},
+		{`rule: older:soon trash`, Rule{}, true},
+		{`rule: subject~"digest trash`, Rule{}, true},
+		{`rule: delete`, Rule{}, true},
+		{`rule: subject~digest`, Rule{}, true},
+		{`rule:`, Rule{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRule(tt.answer, sender)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRule(%q) error = %v, want error %t", tt.answer, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRule(%q) = %+v, want %+v", tt.answer, got, tt.want)
+		}
+	}
+}
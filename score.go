@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+)
+
+// Weights of the signals making up a sender's deletion score, which add up to 1
+const (
+	unreadWeight       = 0.3
+	neverRepliedWeight = 0.2
+	newsletterWeight   = 0.15
+	ageWeight          = 0.1
+	categoryWeight     = 0.1
+	periodicWeight     = 0.15
+)
+
+// Tabs whose emails are usually safe to delete, counted towards the score
+var disposableCategories = []string{"CATEGORY_PROMOTIONS", "CATEGORY_SOCIAL", "CATEGORY_UPDATES", "CATEGORY_FORUMS"}
+
+// Score how safe it is to delete everything from a sender, from 0 (keep) to
+// 1 (delete), combining how much of their email goes unread, whether they
+// have never been replied to, whether they send a newsletter, how long ago
+// they last sent anything, how much of their email lands in the category
+// tabs, and how regularly they send, as perfectly periodic mail is
+// automated. Senders whose mail is filtered away and never opened score
+// highly whatever else they do. Senders who have been replied to, or whose
+// emails are starred or important, have their score reduced
+func deletionScore(sender SenderStats, now time.Time) float64 {
+	if sender.Count == 0 {
+		return 0
+	}
+	count := float64(sender.Count)
+
+	score := unreadWeight * unreadRatio(sender)
+	if sender.RepliesChecked && sender.Replies == 0 {
+		score += neverRepliedWeight
+	}
+	if sender.Newsletter {
+		score += newsletterWeight
+	}
+	if !sender.LastSeen.IsZero() {
+		score += ageWeight * min(now.Sub(sender.LastSeen).Hours()/24/365, 1)
+	}
+	categorised := 0
+	for _, label := range disposableCategories {
+		categorised += sender.Labels[label]
+	}
+	score += categoryWeight * min(float64(categorised)/count, 1)
+	score += periodicWeight * sender.Regularity
+	if isFilteredUnread(sender) {
+		score = max(score, filteredScore)
+	}
+
+	// People the user writes to, and mail they have starred or marked important, are worth keeping
+	if sender.Replies > 0 {
+		score /= 2
+	}
+	kept := float64(sender.Labels["STARRED"] + sender.Labels["IMPORTANT"])
+	score *= 1 - min(kept/count, 1)
+	return score
+}
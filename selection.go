@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse a selection of list numbers such as "1,3,7-10" into the numbers
+// it contains, in order and without duplicates. Numbers must be between 1 and max
+func parseSelection(selection string, max int) ([]int, error) {
+	var selected []int
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(selection, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		// Each part is either a single number or an inclusive range
+		low, high := part, part
+		if dash := strings.Index(part, "-"); dash >= 0 {
+			low, high = part[:dash], part[dash+1:]
+		}
+		start, err := strconv.Atoi(strings.TrimSpace(low))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number or range", part)
+		}
+		end, err := strconv.Atoi(strings.TrimSpace(high))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number or range", part)
+		}
+		if start < 1 || end > max || start > end {
+			return nil, fmt.Errorf("%q is outside the list, which goes from 1 to %d", part, max)
+		}
+
+		for n := start; n <= end; n++ {
+			if !seen[n] {
+				seen[n] = true
+				selected = append(selected, n)
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("nothing was selected")
+	}
+	return selected, nil
+}
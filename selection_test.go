@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		selection string
+		max       int
+		want      []int
+		wantErr   bool
+	}{
+		{"1", 5, []int{1}, false},
+		{"1,3,7-10", 10, []int{1, 3, 7, 8, 9, 10}, false},
+		{" 2 , 4 - 5 ", 5, []int{2, 4, 5}, false},
+		{"3,1-3", 5, []int{3, 1, 2}, false},
+		{"1,,2,", 5, []int{1, 2}, false},
+		{"5-5", 5, []int{5}, false},
+		{"0", 5, nil, true},
+		{"6", 5, nil, true},
+		{"4-6", 5, nil, true},
+		{"3-1", 5, nil, true},
+		{"a", 5, nil, true},
+		{"1-b", 5, nil, true},
+		{"", 5, nil, true},
+		{",", 5, nil, true},
+		{"1", 0, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.selection, tt.max)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelection(%q, %d) error = %v, want error %t", tt.selection, tt.max, err, tt.wantErr)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseSelection(%q, %d) = %v, want %v", tt.selection, tt.max, got, tt.want)
+		}
+	}
+}
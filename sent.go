@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Size of attachments the sent command looks for when --larger-than is not given
+const defaultSentLargerThan = "1M"
+
+// Handles the 'sent' command, which finds emails with large attachments in
+// the Sent folder, groups them by recipient, and offers to delete them.
+// These count against the storage quota just like received emails
+func runSentCommand(ctx context.Context, srv *gmail.Service) error {
+	largerThan := opts.LargerThan
+	if largerThan == "" {
+		largerThan = defaultSentLargerThan
+	}
+	minSize, err := parseSize(largerThan)
+	if err != nil {
+		return err
+	}
+
+	// Let Gmail do the filtering, so only matching emails need to be fetched
+	query := fmt.Sprintf("in:sent has:attachment larger:%d", minSize)
+	ids, err := listMessageIds(ctx, srv, query)
+	if err != nil {
+		return err
+	}
+
+	type recipientStats struct {
+		email string
+		size  int64
+		ids   []string
+	}
+	recipientMap := make(map[string]*recipientStats)
+
+	defer startWork()()
+	progress := newProgress("Scanning sent emails", int64(len(ids)))
+	for _, id := range ids {
+		if interrupted() {
+			break
+		}
+		message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders("To"))
+		progress.Add(1)
+		if err != nil {
+			logger.Warn("Could not get email metadata, continuing", "id", id, "err", err)
+			continue
+		}
+
+		// Group by the first recipient
+		recipient := "(no recipient)"
+		if addresses := extractAddresses(messageHeaders(message)["to"]); len(addresses) > 0 {
+			recipient = addresses[0]
+		}
+		stats, exists := recipientMap[recipient]
+		if !exists {
+			stats = &recipientStats{email: recipient}
+			recipientMap[recipient] = stats
+		}
+		stats.size += message.SizeEstimate
+		stats.ids = append(stats.ids, id)
+	}
+	progress.Finish()
+
+	var recipients []*recipientStats
+	for _, stats := range recipientMap {
+		recipients = append(recipients, stats)
+	}
+	sort.Slice(recipients, func(i, j int) bool {
+		return recipients[i].size > recipients[j].size
+	})
+
+	if len(recipients) == 0 {
+		fmt.Fprintf(display, "No sent emails with attachments larger than %s\n", formatSize(minSize))
+		return nil
+	}
+
+	fmt.Fprintf(display, "\nSent emails with attachments larger than %s, by recipient:\n", formatSize(minSize))
+	table := newTable("#", "Recipient", "Emails", "Size").AlignRight(0, 2, 3)
+	for i, stats := range recipients {
+		table.AddRow(strconv.Itoa(i+1), redactAddress(stats.email), strconv.Itoa(len(stats.ids)), formatSize(stats.size))
+	}
+	table.Render(display)
+
+	for {
+		fmt.Fprintf(display, "Which recipients' sent emails would you like to delete? (numbers e.g. 1,3-5/none):\n")
+		response, _ := readPrompt("select")
+		response = strings.ToLower(response)
+		if response == "none" || response == "" {
+			return nil
+		}
+
+		selected, err := parseSelection(response, len(recipients))
+		if err != nil {
+			fmt.Fprintf(display, "Invalid selection: %v\n", err)
+			continue
+		}
+		for _, n := range selected {
+			stats := recipients[n-1]
+			fmt.Fprintf(display, "Deleting %d sent emails to %s...\n", len(stats.ids), redactAddress(stats.email))
+			if _, err := deleteEmails(ctx, srv, stats.email, stats.ids); err != nil {
+				logger.Error("Error deleting sent emails", "recipient", stats.email, "err", err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Number of messages shown per page by the show command when --page-size is 0
+const showPageSize = 20
+
+// Handles the 'show <sender>' command, which lists every email from the sender
+// with its date, subject, size, labels and whether it has been read, a page
+// at a time, so the mail can be inspected before deciding to delete it all
+func runShowCommand(ctx context.Context, srv *gmail.Service, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: show <sender>")
+	}
+	sender := args[0]
+
+	ids, err := listMessageIds(ctx, srv, "from:"+sender)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "No emails found from %s\n", sender)
+		return nil
+	}
+
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = showPageSize
+	}
+	pages := (len(ids) + pageSize - 1) / pageSize
+
+	// Messages are only fetched when their page is first shown
+	rows := make(map[string][]string)
+	defer startWork()()
+	page := 0
+	for {
+		start := page * pageSize
+		table := newTable("#", "Date", "Subject", "Size", "Labels", "Read").AlignRight(0, 3)
+		for i, id := range ids[start:min(start+pageSize, len(ids))] {
+			if interrupted() {
+				return nil
+			}
+			row, ok := rows[id]
+			if !ok {
+				row, err = messageRow(ctx, srv, id)
+				if err != nil {
+					logger.Warn("Could not get email metadata, continuing", "id", id, "err", err)
+					continue
+				}
+				rows[id] = row
+			}
+			table.AddRow(append([]string{strconv.Itoa(start + i + 1)}, row...)...)
+		}
+
+		fmt.Fprintf(display, "\nEmails from %s (%d in total):\n", redactAddress(sender), len(ids))
+		table.Render(display)
+		if pages == 1 {
+			return nil
+		}
+
+		fmt.Fprintf(display, "Page %d of %d (n)ext, (p)rev, or press enter to finish:\n", page+1, pages)
+		response, ok := readPrompt("page")
+		if !ok {
+			return nil
+		}
+		switch strings.ToLower(response) {
+		case "n", "next":
+			page = min(page+1, pages-1)
+		case "p", "prev":
+			page = max(page-1, 0)
+		case "":
+			return nil
+		default:
+			fmt.Fprintf(display, "Please enter 'n', 'p', or nothing to finish.\n")
+		}
+	}
+}
+
+// Fetch an email and return its date, subject, size, labels and read state as table cells
+func messageRow(ctx context.Context, srv *gmail.Service, id string) ([]string, error) {
+	message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders("Subject", "Date"))
+	if err != nil {
+		return nil, err
+	}
+
+	subject := messageHeaders(message)["subject"]
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	read := "yes"
+	var labels []string
+	for _, label := range message.LabelIds {
+		if label == "UNREAD" {
+			read = "no"
+			continue
+		}
+		labels = append(labels, labelName(ctx, srv, label))
+	}
+
+	return []string{
+		formatDate(messageTime(message)),
+		redactSubject(subject),
+		formatSize(message.SizeEstimate),
+		strings.Join(labels, ", "),
+		read,
+	}, nil
+}
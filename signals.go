@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// Returned by long running operations which stopped early because of a signal
+var errInterrupted = errors.New("interrupted")
+
+var (
+	// Set once SIGINT or SIGTERM has been received
+	interruptReceived atomic.Bool
+
+	// Number of operations currently talking to the Gmail API. While this is
+	// non-zero, a signal lets the in-flight request finish instead of exiting
+	busy atomic.Int32
+)
+
+// Catch SIGINT and SIGTERM. If the API is being used, cancel is called so
+// in-flight reads are abandoned, while an in-flight write finishes and the
+// current operation stops cleanly. Otherwise (e.g. while waiting at a prompt)
+// the program exits straight away. A second signal always exits straight away
+func watchSignals(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		interruptReceived.Store(true)
+		cancel()
+		if busy.Load() == 0 {
+			logger.Warn("Interrupted")
+			os.Exit(interruptedExitCode)
+		}
+		logger.Warn("Interrupted, finishing the current write then stopping. Interrupt again to stop immediately")
+
+		<-signals
+		os.Exit(interruptedExitCode)
+	}()
+}
+
+// Returns true if the run has been asked to stop
+func interrupted() bool {
+	return interruptReceived.Load()
+}
+
+// Mark the start of an operation which talks to the Gmail API. The returned
+// function marks the end of it, and should be deferred
+func startWork() func() {
+	busy.Add(1)
+	return func() {
+		busy.Add(-1)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Returns the local part and domain of an email address
+func splitAddress(email string) (string, string) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email, ""
+	}
+	return strings.ToLower(email[:at]), strings.ToLower(email[at+1:])
+}
+
+// Returns the Levenshtein edit distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// Returns true if two names look like variations of each other,
+// e.g. "news" and "newsletter", or "store" and "store-mail"
+func similarNames(a, b string) bool {
+	// Very short names match too much by chance, so are never treated as similar
+	shortest := min(len(a), len(b))
+	if shortest < 3 {
+		return false
+	}
+	if strings.HasPrefix(a, b) || strings.HasPrefix(b, a) {
+		return true
+	}
+	return shortest >= 5 && editDistance(a, b) <= 2
+}
+
+// Returns true if two different addresses look like they belong to the same
+// sender, e.g. news@store.com, newsletter@store.com and news@store-mail.com
+func similarAddresses(a, b string) bool {
+	localA, domainA := splitAddress(a)
+	localB, domainB := splitAddress(b)
+	if localA == localB && domainA == domainB {
+		return false
+	}
+
+	// Same domain, with a similar local part
+	if domainA == domainB {
+		return similarNames(localA, localB)
+	}
+
+	// Same local part, with a similar domain name (ignoring the TLD)
+	if localA == localB {
+		nameA, _, _ := strings.Cut(domainA, ".")
+		nameB, _, _ := strings.Cut(domainB, ".")
+		return similarNames(nameA, nameB)
+	}
+	return false
+}
+
+// Find senders among candidates which look similar to the given sender, and ask
+// the user which of them the same action should be applied to. The chosen
+// senders are marked as handled so they are not prompted about again
+func confirmSimilarSenders(sender SenderStats, candidates []SenderStats, handled map[string]bool, action string) []SenderStats {
+	var similar []SenderStats
+	for _, candidate := range candidates {
+		if !handled[candidate.Email] && !isProtectedSender(candidate) && similarAddresses(sender.Email, candidate.Email) {
+			similar = append(similar, candidate)
+		}
+	}
+	if len(similar) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(display, "Found %d senders similar to %s:\n", len(similar), redactAddress(sender.Email))
+	table := newTable("#", "Sender", "Emails").AlignRight(0, 2)
+	for i, candidate := range similar {
+		table.AddRow(strconv.Itoa(i+1), redactAddress(candidate.Email), strconv.Itoa(candidate.Count))
+	}
+	table.Render(display)
+
+	for {
+		fmt.Fprintf(display, "Would you like to %s these too? (all/none/numbers e.g. 1,3-5):\n", action)
+		response, _ := readPrompt("similar")
+		response = strings.ToLower(response)
+
+		if response == "none" || response == "" {
+			return nil
+		}
+
+		var chosen []SenderStats
+		if response == "all" {
+			chosen = similar
+		} else {
+			selected, err := parseSelection(response, len(similar))
+			if err != nil {
+				fmt.Fprintf(display, "Please enter 'all', 'none' or numbers from the list above (%v).\n", err)
+				continue
+			}
+			for _, n := range selected {
+				chosen = append(chosen, similar[n-1])
+			}
+		}
+
+		for _, c := range chosen {
+			handled[c.Email] = true
+		}
+		return chosen
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestSimilarAddresses(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"news@store.com", "newsletter@store.com", true},
+		{"news@store.com", "news@store-mail.com", true},
+		{"sales@store.com", "sails@store.com", true},
+		{"news@store.com", "news@store.com", false},
+		{"News@Store.com", "news@store.com", false},
+		{"ab@store.com", "abc@store.com", false},
+		{"alice@store.com", "bob@store.com", false},
+		{"news@store.com", "deals@shop.com", false},
+		{"news@store.com", "news@shop.com", false},
+		{"offers@store.com", "news@store-mail.com", false},
+	}
+	for _, tt := range tests {
+		if got := similarAddresses(tt.a, tt.b); got != tt.want {
+			t.Errorf("similarAddresses(%q, %q) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+		if got := similarAddresses(tt.b, tt.a); got != tt.want {
+			t.Errorf("similarAddresses(%q, %q) = %t, want %t", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
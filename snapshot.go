@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// The results of a scan saved to disk, so they can be reloaded later without rescanning
+type Snapshot struct {
+	CreatedAt time.Time     `json:"created_at"`
+	Profile   string        `json:"profile"`
+	Partial   bool          `json:"partial"`
+	Senders   []SenderStats `json:"senders"`
+}
+
+// Returns the path scans are saved to, either from --snapshot
+// or the default snapshot file for the selected profile
+func snapshotPath() string {
+	if opts.SnapshotPath != "" {
+		return opts.SnapshotPath
+	}
+	return profileFile("snapshot", ".json")
+}
+
+// Save the sender statistics from a scan to the snapshot file. Partial
+// marks a scan which was interrupted before it finished
+func saveSnapshot(path string, senderStats []SenderStats, partial bool) error {
+	snapshot := Snapshot{
+		CreatedAt: time.Now(),
+		Profile:   opts.Profile,
+		Partial:   partial,
+		Senders:   senderStats,
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Load a snapshot saved by an earlier scan
+func loadSnapshot(path string) (Snapshot, error) {
+	var snapshot Snapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(data, &snapshot)
+	return snapshot, err
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Handles the 'spam' command, which shows how much spam each sender has
+// sent, and 'spam purge <age>', which permanently deletes spam older than
+// the given age
+func runSpamCommand(ctx context.Context, srv *gmail.Service, args []string) error {
+	if len(args) == 0 {
+		return showSpamReport(ctx, srv)
+	}
+	if len(args) == 2 && args[0] == "purge" {
+		age, err := parseAge(args[1])
+		if err != nil {
+			return err
+		}
+		return purgeSpam(ctx, srv, age, time.Now())
+	}
+	return fmt.Errorf("usage: spam [purge <age>]")
+}
+
+// List the IDs of every email with the given label matching the query. Spam
+// and Trash are searched too, so this also lists what is in those folders
+func listLabelIds(ctx context.Context, srv *gmail.Service, label, query string) ([]string, error) {
+	var ids []string
+	pageToken := ""
+	for {
+		req := srv.Users.Messages.List("me").LabelIds(label).IncludeSpamTrash(true).Q(query)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		r, err := callAPI(ctx, "messages.list", req)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range r.Messages {
+			ids = append(ids, msg.Id)
+		}
+		if r.NextPageToken == "" {
+			return ids, nil
+		}
+		pageToken = r.NextPageToken
+	}
+}
+
+// Print how many emails each sender has in the Spam folder and how much storage they take up
+func showSpamReport(ctx context.Context, srv *gmail.Service) error {
+	ids, err := listLabelIds(ctx, srv, "SPAM", "")
+	if err != nil {
+		return err
+	}
+
+	type spamStats struct {
+		email string
+		count int
+		size  int64
+	}
+	senderMap := make(map[string]*spamStats)
+	var total int64
+
+	defer startWork()()
+	progress := newProgress("Scanning spam", int64(len(ids)))
+	for _, id := range ids {
+		if interrupted() {
+			break
+		}
+		message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", id).Format("metadata").MetadataHeaders("From"))
+		progress.Add(1)
+		if err != nil {
+			logger.Warn("Could not get email metadata, continuing", "id", id, "err", err)
+			continue
+		}
+
+		email := "(unknown sender)"
+		if from, ok := messageHeaders(message)["from"]; ok {
+			email = extractEmail(from)
+		}
+		stats, exists := senderMap[email]
+		if !exists {
+			stats = &spamStats{email: email}
+			senderMap[email] = stats
+		}
+		stats.count++
+		stats.size += message.SizeEstimate
+		total += message.SizeEstimate
+	}
+	progress.Finish()
+
+	var senders []*spamStats
+	for _, stats := range senderMap {
+		senders = append(senders, stats)
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return senders[i].count > senders[j].count
+	})
+
+	fmt.Fprintf(display, "\nSpam contains %d emails (%s) from %d senders:\n", len(ids), formatSize(total), len(senders))
+	table := newTable("#", "Sender", "Emails", "Size").AlignRight(0, 2, 3)
+	for i, stats := range senders {
+		table.AddRow(strconv.Itoa(i+1), redactAddress(stats.email), strconv.Itoa(stats.count), formatSize(stats.size))
+	}
+	table.Render(display)
+	return nil
+}
+
+// Permanently delete spam older than the given age, after confirming. This
+// needs the full https://mail.google.com/ scope, as the modify scope cannot
+// delete messages
+func purgeSpam(ctx context.Context, srv *gmail.Service, age time.Duration, now time.Time) error {
+	cutoff := now.Add(-age)
+	ids, err := listLabelIds(ctx, srv, "SPAM", "before:"+cutoff.Format("2006/01/02"))
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "No spam older than %s\n", formatDate(cutoff))
+		return nil
+	}
+
+	fmt.Fprintf(display, "%d spam emails are from before %s\n", len(ids), formatDate(cutoff))
+	if !confirmPermanent() {
+		return nil
+	}
+	deleted := deletePermanently(ctx, srv, "Deleting spam", ids)
+	appendJournal("delete", "spam", deleted)
+
+	fmt.Fprintf(display, "Permanently deleted %d spam emails\n", len(deleted))
+	return nil
+}
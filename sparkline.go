@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Number of months shown in a sender's sparkline
+const sparklineMonths = 12
+
+// Characters used to draw sparklines, from the quietest month to the busiest
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// Draw a sparkline of the number of emails received in each of the last
+// sparklineMonths months, oldest first and ending with the current month,
+// so it is clear whether a sender is ramping up or has gone quiet
+func sparkline(monthly map[string]int, now time.Time) string {
+	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	counts := make([]int, sparklineMonths)
+	peak := 0
+	for i := range counts {
+		counts[i] = monthly[firstOfMonth.AddDate(0, i-sparklineMonths+1, 0).Format("2006-01")]
+		peak = max(peak, counts[i])
+	}
+
+	var line strings.Builder
+	for _, count := range counts {
+		switch {
+		case count == 0:
+			line.WriteRune(' ')
+		default:
+			line.WriteRune(sparkTicks[(count*(len(sparkTicks)-1))/peak])
+		}
+	}
+	return line.String()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Emails and conversations which must never be deleted, with the reason
+// each one is kept, found once per run the first time anything is deleted
+var (
+	keptMessages map[string]string
+	keptThreads  map[string]string
+)
+
+// Returns the Gmail searches for emails which are never deleted, keyed by
+// the reason given when they are skipped
+func keptQueries() map[string]string {
+	queries := make(map[string]string)
+	if opts.KeepStarred {
+		queries["starred or important"] = "{is:starred is:important}"
+	}
+	if opts.KeepAttachments {
+		queries["with attachments"] = "has:attachment"
+	}
+	if opts.ExcludeQuery != "" {
+		queries["excluded"] = "(" + opts.ExcludeQuery + ")"
+	}
+	return queries
+}
+
+// Find the emails which are never deleted, and the conversations they are in
+func loadKeptMessages(ctx context.Context, srv *gmail.Service) error {
+	if keptMessages != nil {
+		return nil
+	}
+	messages := make(map[string]string)
+	threads := make(map[string]string)
+	for reason, query := range keptQueries() {
+		pageToken := ""
+		for {
+			req := srv.Users.Messages.List("me").Q(query)
+			if pageToken != "" {
+				req.PageToken(pageToken)
+			}
+			r, err := callAPI(ctx, "messages.list", req)
+			if err != nil {
+				return fmt.Errorf("could not find the %s emails to keep: %w", reason, err)
+			}
+			for _, msg := range r.Messages {
+				messages[msg.Id] = reason
+				threads[msg.ThreadId] = reason
+			}
+			if r.NextPageToken == "" {
+				break
+			}
+			pageToken = r.NextPageToken
+		}
+	}
+	logger.Debug("Found emails which are never deleted", "emails", len(messages), "conversations", len(threads))
+	keptMessages, keptThreads = messages, threads
+	return nil
+}
+
+// Remove the emails or conversations which must never be deleted from the
+// given IDs, printing how many were skipped for each reason
+func withoutKept(ctx context.Context, srv *gmail.Service, ids []string, threads bool) ([]string, error) {
+	if len(keptQueries()) == 0 {
+		return ids, nil
+	}
+	if err := loadKeptMessages(ctx, srv); err != nil {
+		return nil, err
+	}
+	kept := keptMessages
+	noun := "emails"
+	if threads {
+		kept = keptThreads
+		noun = "conversations"
+	}
+
+	var remaining []string
+	skipped := make(map[string]int)
+	for _, id := range ids {
+		if reason, ok := kept[id]; ok {
+			skipped[reason]++
+			continue
+		}
+		remaining = append(remaining, id)
+	}
+	var parts []string
+	for reason, count := range skipped {
+		parts = append(parts, fmt.Sprintf("%d %s %s", count, reason, noun))
+	}
+	if len(parts) > 0 {
+		sort.Strings(parts)
+		fmt.Fprintf(display, "Skipping %s\n", strings.Join(parts, ", "))
+	}
+	return remaining, nil
+}
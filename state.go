@@ -0,0 +1,163 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A file which holds state the tool needs to keep between runs. Name is
+// the name the file is stored under in a state archive, and Path is
+// where the file lives on this machine
+type StateFile struct {
+	Name string
+	Path string
+}
+
+// Every file making up the tool's state. Anything added here
+// is automatically included in state exports and imports
+func stateFiles() []StateFile {
+	files := []StateFile{
+		{Name: "credentials.json", Path: opts.CredentialsFile},
+		{Name: "token.json", Path: opts.TokenFile},
+		{Name: "token-readonly.json", Path: profileFile("token-readonly", ".json")},
+		{Name: "token-full.json", Path: profileFile("token-full", ".json")},
+		{Name: "journal.jsonl", Path: journalPath()},
+		{Name: "rules.yaml", Path: rulesPath()},
+		{Name: "snapshot.json", Path: snapshotPath()},
+		{Name: "cache.json", Path: cachePath()},
+	}
+	if opts.ConfigPath != "" {
+		files = append(files, StateFile{Name: "config.yaml", Path: opts.ConfigPath})
+	}
+	return files
+}
+
+// Handles the 'state export <archive>' and 'state import <archive>' commands
+func runStateCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: state export|import <archive.tar.gz>")
+	}
+
+	switch args[0] {
+	case "export":
+		return exportState(args[1])
+	case "import":
+		return importState(args[1])
+	default:
+		return fmt.Errorf("unknown state command %q, expected 'export' or 'import'", args[0])
+	}
+}
+
+// Bundle every state file which exists into a gzipped tar archive
+func exportState(archivePath string) error {
+	out, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	exported := 0
+	for _, file := range stateFiles() {
+		data, err := os.ReadFile(file.Path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("could not read %s: %v", file.Path, err)
+		}
+
+		header := &tar.Header{
+			Name:    file.Name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+		logger.Info("Exported state file", "path", file.Path)
+		exported++
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(display, "Wrote %d state files to %s\n", exported, archivePath)
+	return nil
+}
+
+// Restore the state files in the given archive to where this machine keeps them.
+// Existing files are only overwritten if --force was given
+func importState(archivePath string) error {
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("%s is not a state archive: %v", archivePath, err)
+	}
+	tr := tar.NewReader(gz)
+
+	// Only files the tool knows about are restored
+	known := make(map[string]StateFile)
+	for _, file := range stateFiles() {
+		known[file.Name] = file
+	}
+
+	imported := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		file, ok := known[header.Name]
+		if !ok {
+			logger.Warn("Skipping unknown file in archive", "name", header.Name)
+			continue
+		}
+
+		if _, err := os.Stat(file.Path); err == nil && !opts.Force {
+			return fmt.Errorf("%s already exists, use --force to overwrite it", file.Path)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if dir := filepath.Dir(file.Path); dir != "." {
+			if err := os.MkdirAll(dir, 0700); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(file.Path, data, 0600); err != nil {
+			return err
+		}
+		logger.Info("Imported state file", "path", file.Path)
+		imported++
+	}
+
+	fmt.Fprintf(display, "Restored %d state files from %s\n", imported, archivePath)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+var (
+	// The account's Google storage usage, fetched at the start of the run with --storage
+	storageQuota *drive.AboutStorageQuota
+
+	// Estimated storage taken up by the emails deleted this run
+	freedBytes int64
+)
+
+// Fetch the account's Google storage usage from the Drive API, which reports
+// the quota shared by Gmail, Drive and Photos, and print an overview of it
+func showStorageOverview(ctx context.Context, client *http.Client) {
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		logger.Warn("Unable to create Drive service", "err", err)
+		return
+	}
+	about, err := callAPI(ctx, "about.get", srv.About.Get().Fields("storageQuota"))
+	if err != nil {
+		logger.Warn("Could not get storage usage, the token may need the Drive metadata scope", "err", err)
+		return
+	}
+	storageQuota = about.StorageQuota
+
+	// Drive reports its own share, so the rest is Gmail and Photos
+	quota := storageQuota
+	other := quota.Usage - quota.UsageInDrive - quota.UsageInDriveTrash
+	if quota.Limit > 0 {
+		fmt.Fprintf(display, "Google storage: %s of %s used (%s)\n", formatSize(quota.Usage), formatSize(quota.Limit), formatPercent(float64(quota.Usage)/float64(quota.Limit)))
+	} else {
+		fmt.Fprintf(display, "Google storage: %s used, with no limit\n", formatSize(quota.Usage))
+	}
+	fmt.Fprintf(display, "Drive uses %s (%s of it in Drive's trash), leaving %s for Gmail and Photos\n",
+		formatSize(quota.UsageInDrive+quota.UsageInDriveTrash), formatSize(quota.UsageInDriveTrash), formatSize(other))
+}
+
+// Print how much storage the emails deleted this run take up, against the
+// account's storage limit. Trashed emails only stop counting once the Trash is emptied
+func printStorageFreed() {
+	if storageQuota == nil || freedBytes == 0 {
+		return
+	}
+	when := "which is freed once the Trash is emptied"
+	if opts.Permanent {
+		when = "which has been freed straight away"
+	}
+	if storageQuota.Limit > 0 {
+		fmt.Fprintf(display, "\nThe emails deleted this run take up about %s, %s of your %s storage limit, %s\n",
+			formatSize(freedBytes), formatPercent(float64(freedBytes)/float64(storageQuota.Limit)), formatSize(storageQuota.Limit), when)
+	} else {
+		fmt.Fprintf(display, "\nThe emails deleted this run take up about %s, %s\n", formatSize(freedBytes), when)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Number of subject lines shown for each sender at the prompt
+const topSubjectCount = 3
+
+var (
+	// Reply and forward prefixes, which are left out when grouping subjects
+	replyPrefix = regexp.MustCompile(`^(?i)((re|fwd?|aw|wg)\s*:\s*)+`)
+
+	// Runs of digits, such as order numbers and dates, which vary between otherwise identical subjects
+	subjectNumber = regexp.MustCompile(`[0-9]+`)
+)
+
+// Reduce a subject line to a form shared by every email of the same kind, so
+// "Re: Order 1234 has shipped" and "Order 5678 has shipped" are both counted
+// as "Order # has shipped"
+func normaliseSubject(subject string) string {
+	subject = strings.Join(strings.Fields(subject), " ")
+	subject = replyPrefix.ReplaceAllString(subject, "")
+	subject = subjectNumber.ReplaceAllString(subject, "#")
+	if subject == "" {
+		return "(no subject)"
+	}
+	return subject
+}
+
+// Describe the sender's most common subject lines with how many emails had
+// each, e.g. "Your weekly digest" ×212, or "" if no subjects were recorded
+func describeSubjects(sender SenderStats) string {
+	subjects := make([]string, 0, len(sender.Subjects))
+	for subject := range sender.Subjects {
+		subjects = append(subjects, subject)
+	}
+	sort.Slice(subjects, func(i, j int) bool {
+		a, b := sender.Subjects[subjects[i]], sender.Subjects[subjects[j]]
+		if a != b {
+			return a > b
+		}
+		return subjects[i] < subjects[j]
+	})
+
+	var parts []string
+	for _, subject := range subjects[:min(len(subjects), topSubjectCount)] {
+		parts = append(parts, fmt.Sprintf("%q ×%d", subject, sender.Subjects[subject]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Width assumed when the terminal width cannot be found
+const defaultTerminalWidth = 100
+
+// Columns are never truncated narrower than this
+const minColumnWidth = 8
+
+// Renders rows of text as a table which fits the width of the terminal,
+// truncating the widest columns when there is not enough room
+type Table struct {
+	headers []string
+	right   []bool
+	rows    [][]string
+	colors  []string
+}
+
+// Create a table with the given column headings
+func newTable(headers ...string) *Table {
+	return &Table{
+		headers: headers,
+		right:   make([]bool, len(headers)),
+	}
+}
+
+// Right align the given columns, which is used for numbers
+func (t *Table) AlignRight(columns ...int) *Table {
+	for _, c := range columns {
+		t.right[c] = true
+	}
+	return t
+}
+
+// Add a row to the table. Missing cells are left blank
+func (t *Table) AddRow(cells ...string) {
+	t.AddColoredRow("", cells...)
+}
+
+// Add a row to the table, drawn in the given colour
+func (t *Table) AddColoredRow(color string, cells ...string) {
+	row := make([]string, len(t.headers))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+	t.colors = append(t.colors, color)
+}
+
+// Returns the width available to draw tables in. $COLUMNS takes
+// precedence, then the size of the terminal
+func tableWidth(w io.Writer) int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if file, ok := w.(*os.File); ok {
+		if width := terminalWidth(file); width > 0 {
+			return width
+		}
+	}
+	return defaultTerminalWidth
+}
+
+// Write the table, with borders if --borders was given
+func (t *Table) Render(w io.Writer) {
+	widths := make([]int, len(t.headers))
+	for c, header := range t.headers {
+		widths[c] = utf8.RuneCountInString(header)
+	}
+	for _, row := range t.rows {
+		for c, cell := range row {
+			widths[c] = max(widths[c], utf8.RuneCountInString(cell))
+		}
+	}
+
+	// Each column is separated by two characters, or three with borders
+	separator := 2
+	if opts.Borders {
+		separator = 3
+	}
+	available := tableWidth(w) - separator*(len(widths)-1)
+	if opts.Borders {
+		available -= 4
+	}
+
+	// Shrink the widest column until the table fits, or nothing can shrink further
+	for {
+		total, widest := 0, 0
+		for c, width := range widths {
+			total += width
+			if width > widths[widest] {
+				widest = c
+			}
+		}
+		if total <= available || widths[widest] <= minColumnWidth {
+			break
+		}
+		widths[widest]--
+	}
+
+	line := func(cells []string) string {
+		padded := make([]string, len(cells))
+		for c, cell := range cells {
+			cell = truncate(cell, widths[c])
+			padding := strings.Repeat(" ", widths[c]-utf8.RuneCountInString(cell))
+			if t.right[c] {
+				padded[c] = padding + cell
+			} else {
+				padded[c] = cell + padding
+			}
+		}
+		if opts.Borders {
+			return "| " + strings.Join(padded, " | ") + " |"
+		}
+		return strings.TrimRight(strings.Join(padded, "  "), " ")
+	}
+
+	border := ""
+	if opts.Borders {
+		parts := make([]string, len(widths))
+		for c, width := range widths {
+			parts[c] = strings.Repeat("-", width+2)
+		}
+		border = "+" + strings.Join(parts, "+") + "+"
+		fmt.Fprintln(w, border)
+	}
+	fmt.Fprintln(w, line(t.headers))
+	if opts.Borders {
+		fmt.Fprintln(w, border)
+	} else {
+		underline := make([]string, len(widths))
+		for c, width := range widths {
+			underline[c] = strings.Repeat("-", width)
+		}
+		fmt.Fprintln(w, strings.Join(underline, "  "))
+	}
+	for r, row := range t.rows {
+		text := line(row)
+		if t.colors[r] != "" {
+			text = colorize(t.colors[r], text)
+		}
+		fmt.Fprintln(w, text)
+	}
+	if opts.Borders {
+		fmt.Fprintln(w, border)
+	}
+}
+
+// Shorten text to at most width characters, marking where it was cut
+func truncate(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// Format a date for display in a table
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// Returns the width of the terminal attached to the given file in columns,
+// or 0 if it is not known
+func terminalWidth(file *os.File) int {
+	return 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A file which is not a terminal has no width on any platform, so tables
+// fall back to $COLUMNS or the default width
+func TestTerminalWidthNotATerminal(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if width := terminalWidth(file); width != 0 {
+		t.Errorf("terminalWidth(file) = %d, want 0", width)
+	}
+}
+
+func TestTableWidth(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tests := []struct {
+		name    string
+		columns string
+		w       io.Writer
+		want    int
+	}{
+		{"columns set", "120", &bytes.Buffer{}, 120},
+		{"columns set for a file", "60", file, 60},
+		{"columns unset", "", &bytes.Buffer{}, defaultTerminalWidth},
+		{"columns not a number", "wide", &bytes.Buffer{}, defaultTerminalWidth},
+		{"columns zero", "0", &bytes.Buffer{}, defaultTerminalWidth},
+		{"file which is not a terminal", "", file, defaultTerminalWidth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COLUMNS", tt.columns)
+			if got := tableWidth(tt.w); got != tt.want {
+				t.Errorf("tableWidth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
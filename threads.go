@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// The sender and time of the earliest email seen in a conversation
+type threadStart struct {
+	sender string
+	time   time.Time
+}
+
+// Note the email as the start of its conversation if it is the earliest one seen so far
+func recordThread(starts map[string]threadStart, message *gmail.Message, sender string) {
+	if message.ThreadId == "" {
+		return
+	}
+	t := messageTime(message)
+	if start, exists := starts[message.ThreadId]; exists && !t.Before(start.time) {
+		return
+	}
+	starts[message.ThreadId] = threadStart{sender: sender, time: t}
+}
+
+// Give each conversation to the sender who started it, so that every
+// conversation is counted once no matter how many replies it has
+func assignThreads(senderMap map[string]*SenderStats, starts map[string]threadStart) {
+	for id, start := range starts {
+		if stats, exists := senderMap[start.sender]; exists {
+			stats.Threads = append(stats.Threads, id)
+		}
+	}
+}
+
+// Move whole conversations to the Trash, reporting the outcome. Every email in
+// a conversation is trashed together, including replies from other senders
+func deleteThreads(ctx context.Context, srv *gmail.Service, sender string, threadIDs []string) (DeletionResult, error) {
+	defer startWork()()
+	var deleteErrors []string
+	var trashed []string
+	successCount := 0
+	defer func() {
+		appendJournal("trash", sender, trashed)
+	}()
+
+	// Conversations containing an email which must never be deleted are left alone
+	threadIDs, err := withoutKept(ctx, srv, threadIDs, true)
+	if err != nil {
+		return DeletionResult{}, err
+	}
+
+	progress := newProgress("Deleting conversations", int64(len(threadIDs)))
+	for _, id := range threadIDs {
+		if interrupted() {
+			break
+		}
+
+		thread, err := callWriteAPI(ctx, "threads.trash", srv.Users.Threads.Trash("me", id))
+		progress.Add(1)
+		if err != nil {
+			deleteErrors = append(deleteErrors, fmt.Sprintf("failed to delete conversation %s: %v", id, err))
+			continue
+		}
+
+		// The emails in the conversation are journalled, so the undo command can restore them
+		successCount++
+		for _, message := range thread.Messages {
+			trashed = append(trashed, message.Id)
+			logDeletion(message.Id, sender, "", "trashed")
+		}
+		logger.Debug("Moved conversation to trash", "id", id, "emails", len(thread.Messages))
+	}
+	progress.Finish()
+	if interrupted() {
+		fmt.Fprintf(display, "Deletion interrupted, %d conversations were not processed\n", len(threadIDs)-successCount-len(deleteErrors))
+	}
+
+	failedMessages += len(deleteErrors)
+
+	fmt.Fprintf(display, "\nDeletion Summary:\n")
+	fmt.Fprintf(display, "Successfully deleted: %d conversations (%d emails)\n", successCount, len(trashed))
+	if len(deleteErrors) > 0 {
+		fmt.Fprintf(display, "%s\n", colorize(colorRed, fmt.Sprintf("Failed to delete: %d conversations", len(deleteErrors))))
+		fmt.Fprintf(display, "Error details:\n")
+		for _, errMsg := range deleteErrors {
+			fmt.Fprintf(display, "- %s\n", colorize(colorRed, errMsg))
+		}
+		return DeletionResult{Deleted: successCount, Errors: deleteErrors, Ids: trashed}, fmt.Errorf("some deletions failed: %d errors occurred", len(deleteErrors))
+	}
+	return DeletionResult{Deleted: successCount, Errors: deleteErrors, Ids: trashed}, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Top-level domains which are cheap to register and turn up in spam waves far
+// more often than in legitimate mail
+var unusualTLDs = []string{
+	"bond", "buzz", "cam", "cf", "click", "cyou", "ga", "gq", "icu", "link", "loan", "ml",
+	"monster", "rest", "sbs", "tk", "top", "work", "xyz", "zip",
+}
+
+// Senders and email from one top-level domain
+type tldStats struct {
+	tld     string
+	senders []SenderStats
+	count   int
+	size    int64
+}
+
+// Returns the top-level domain of an email address, e.g. "com" or "uk"
+func topLevelDomain(email string) string {
+	_, domain := splitAddress(email)
+	if dot := strings.LastIndex(domain, "."); dot >= 0 {
+		return domain[dot+1:]
+	}
+	return domain
+}
+
+// Handles the 'tlds' command, which groups senders by the top-level domain
+// of their address, flags top-level domains often used by spammers, and
+// offers to delete everything from the senders under chosen domains
+func runTLDsCommand(ctx context.Context, srv *gmail.Service, senderStats []SenderStats) error {
+	tldMap := make(map[string]*tldStats)
+	for _, sender := range senderStats {
+		tld := topLevelDomain(sender.Email)
+		stats, exists := tldMap[tld]
+		if !exists {
+			stats = &tldStats{tld: tld}
+			tldMap[tld] = stats
+		}
+		stats.senders = append(stats.senders, sender)
+		stats.count += sender.Count
+		stats.size += sender.Size
+	}
+
+	var tlds []*tldStats
+	for _, stats := range tldMap {
+		tlds = append(tlds, stats)
+	}
+	sort.Slice(tlds, func(i, j int) bool {
+		return tlds[i].count > tlds[j].count
+	})
+
+	fmt.Fprintf(display, "\nSenders by top-level domain:\n")
+	table := newTable("#", "Domain", "Senders", "Emails", "Size", "Notes").AlignRight(0, 2, 3, 4)
+	for i, stats := range tlds {
+		note, color := "", ""
+		if slices.Contains(unusualTLDs, stats.tld) {
+			note, color = "often used for spam", colorYellow
+		}
+		table.AddColoredRow(color, strconv.Itoa(i+1), "."+stats.tld, strconv.Itoa(len(stats.senders)), strconv.Itoa(stats.count), formatSize(stats.size), note)
+	}
+	table.Render(display)
+
+	for {
+		fmt.Fprintf(display, "Which domains would you like to delete every email from? (numbers e.g. 1,3-5/none):\n")
+		response, _ := readPrompt("select")
+		response = strings.ToLower(response)
+		if response == "none" || response == "" {
+			return nil
+		}
+
+		selected, err := parseSelection(response, len(tlds))
+		if err != nil {
+			fmt.Fprintf(display, "Invalid selection: %v\n", err)
+			continue
+		}
+		for _, n := range selected {
+			for _, sender := range tlds[n-1].senders {
+				if interrupted() {
+					return nil
+				}
+				if isProtectedSender(sender) {
+					continue
+				}
+				deleteSender(ctx, srv, sender)
+			}
+		}
+		return nil
+	}
+}
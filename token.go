@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+)
+
+// Endpoint which reports the scopes an access token was granted
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// Create a client from an OAuth token obtained by another system, read from
+// the given file or from the first line of stdin if it is "-". The token may
+// be the JSON saved by this tool or a bare access token. It is used as it is,
+// so it is never refreshed and the local OAuth flow is never started
+func externalClient(source string, scopes []string) (*http.Client, error) {
+	var data string
+	if source == "-" {
+		line, ok := readLine()
+		if !ok {
+			return nil, fmt.Errorf("no token given on stdin")
+		}
+		data = line
+	} else {
+		contents, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		data = strings.TrimSpace(string(contents))
+	}
+
+	tok := &oauth2.Token{}
+	if strings.HasPrefix(data, "{") {
+		if err := json.Unmarshal([]byte(data), tok); err != nil {
+			return nil, fmt.Errorf("could not parse token: %v", err)
+		}
+	} else {
+		tok.AccessToken = data
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("token has no access token")
+	}
+
+	if err := checkTokenScopes(tok.AccessToken, scopes); err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(tok)), nil
+}
+
+// Check with Google that the access token is valid and was granted every one of the given scopes
+func checkTokenScopes(accessToken string, scopes []string) error {
+	resp, err := http.Get(tokenInfoURL + "?access_token=" + url.QueryEscape(accessToken))
+	if err != nil {
+		return fmt.Errorf("could not check token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token was rejected: %s", resp.Status)
+	}
+
+	var info struct {
+		Scope string `json:"scope"`
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return fmt.Errorf("could not parse token info: %v", err)
+	}
+
+	// Broader Gmail scopes include the narrower ones
+	granted := make(map[string]bool)
+	for _, scope := range strings.Fields(info.Scope) {
+		granted[scope] = true
+		switch scope {
+		case gmail.MailGoogleComScope:
+			granted[gmail.GmailModifyScope] = true
+			granted[gmail.GmailReadonlyScope] = true
+		case gmail.GmailModifyScope:
+			granted[gmail.GmailReadonlyScope] = true
+		}
+	}
+	var missing []string
+	for _, scope := range scopes {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("token is missing scopes: %s", strings.Join(missing, ", "))
+	}
+
+	logger.Info("Using externally provided token", "account", info.Email)
+	return nil
+}
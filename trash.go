@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Gmail permanently deletes anything which has been in the Trash this long
+const gmailTrashRetention = 30 * 24 * time.Hour
+
+// Handles the 'trash' command, which shows what is in the Trash, and
+// 'trash purge', which permanently deletes messages the tool trashed
+// once they are older than the --purge-after retention period
+func runTrashCommand(ctx context.Context, srv *gmail.Service, args []string) error {
+	if len(args) == 0 {
+		return showTrashInventory(ctx, srv)
+	}
+	if len(args) == 1 && args[0] == "purge" {
+		return purgeTrash(ctx, srv, time.Now())
+	}
+	return fmt.Errorf("usage: trash [purge]")
+}
+
+// Print what is currently in the Trash, grouped by the original sender and
+// by the age of the emails, with how much storage emptying it would free
+func showTrashInventory(ctx context.Context, srv *gmail.Service) error {
+	// Note which messages were put in the Trash by this tool
+	entries, err := readJournal()
+	if err != nil {
+		return err
+	}
+	trashedByTool := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Action == "trash" {
+			trashedByTool[entry.ID] = true
+		}
+	}
+
+	type trashStats struct {
+		email  string
+		count  int
+		size   int64
+		oldest time.Time
+		byTool int
+	}
+	senderMap := make(map[string]*trashStats)
+	ages := make([]AgeReport, len(ageBuckets))
+	for i, bucket := range ageBuckets {
+		ages[i].Age = bucket.name
+	}
+	var totalSize int64
+	now := time.Now()
+
+	defer startWork()()
+	progress := newProgress("Scanning trash", 0)
+	pageToken := ""
+	for {
+		req := srv.Users.Messages.List("me").LabelIds("TRASH").IncludeSpamTrash(true)
+		if pageToken != "" {
+			req.PageToken(pageToken)
+		}
+		r, err := callAPI(ctx, "messages.list", req)
+		if err != nil {
+			return err
+		}
+
+		progress.Page()
+
+		for _, msg := range r.Messages {
+			if interrupted() {
+				break
+			}
+			message, err := callAPI(ctx, "messages.get", srv.Users.Messages.Get("me", msg.Id).Format("metadata").MetadataHeaders("From", "Date"))
+			progress.Add(1)
+			if err != nil {
+				logger.Warn("Could not get email metadata, continuing", "id", msg.Id, "err", err)
+				continue
+			}
+
+			email := "(unknown sender)"
+			if from, ok := messageHeaders(message)["from"]; ok {
+				email = extractEmail(from)
+			}
+
+			stats, exists := senderMap[email]
+			if !exists {
+				stats = &trashStats{email: email}
+				senderMap[email] = stats
+			}
+			stats.count++
+			stats.size += message.SizeEstimate
+			totalSize += message.SizeEstimate
+			if trashedByTool[msg.Id] {
+				stats.byTool++
+			}
+
+			// Sort the email into an age bucket by when it arrived, not when it was trashed
+			if received := messageTime(message); !received.IsZero() {
+				if stats.oldest.IsZero() || received.Before(stats.oldest) {
+					stats.oldest = received
+				}
+				age := (now.Year()-received.Year())*12 + int(now.Month()-received.Month())
+				bucket := 0
+				for i, b := range ageBuckets {
+					if age >= b.months {
+						bucket = i
+					}
+				}
+				ages[bucket].Count++
+				ages[bucket].Size += message.SizeEstimate
+			}
+		}
+
+		if r.NextPageToken == "" || interrupted() {
+			break
+		}
+		pageToken = r.NextPageToken
+	}
+
+	var senders []*trashStats
+	total := 0
+	for _, stats := range senderMap {
+		senders = append(senders, stats)
+		total += stats.count
+	}
+	progress.Finish()
+	sort.Slice(senders, func(i, j int) bool {
+		return senders[i].size > senders[j].size
+	})
+
+	fmt.Fprintf(display, "\nTrash contains %d emails (%s) from %d senders:\n", total, formatSize(totalSize), len(senders))
+	table := newTable("#", "Sender", "Emails", "Size", "Oldest", "Trashed by tool").AlignRight(0, 2, 3, 5)
+	for i, stats := range senders {
+		table.AddRow(strconv.Itoa(i+1), redactAddress(stats.email), strconv.Itoa(stats.count), formatSize(stats.size), formatDate(stats.oldest), strconv.Itoa(stats.byTool))
+	}
+	table.Render(display)
+
+	fmt.Fprintf(display, "\nTrashed emails by when they arrived:\n")
+	ageTable := newTable("Age", "Emails", "Size").AlignRight(1, 2)
+	for _, age := range ages {
+		ageTable.AddRow(age.Age, strconv.Itoa(age.Count), formatSize(age.Size))
+	}
+	ageTable.Render(display)
+
+	if totalSize > 0 {
+		fmt.Fprintf(display, "Emptying the Trash would free %s straight away, instead of waiting up to 30 days for Gmail to delete it\n", formatSize(totalSize))
+	}
+	return nil
+}
+
+// Permanently delete messages this tool moved to the Trash which have been
+// there longer than the retention period. This needs the full
+// https://mail.google.com/ scope, as the modify scope cannot delete messages
+func purgeTrash(ctx context.Context, srv *gmail.Service, now time.Time) error {
+	if opts.PurgeAfter == "" {
+		return fmt.Errorf("no retention period set, use --purge-after or purge_after in the config file")
+	}
+	retention, err := parseAge(opts.PurgeAfter)
+	if err != nil {
+		return err
+	}
+	if retention >= gmailTrashRetention {
+		logger.Warn("Gmail empties the Trash after 30 days, so messages will not be kept for the whole retention period", "purge_after", opts.PurgeAfter)
+	}
+
+	entries, err := readJournal()
+	if err != nil {
+		return err
+	}
+
+	// Find messages which were trashed before the cutoff and have not since
+	// been purged or restored. Later journal entries supersede earlier ones
+	latest := make(map[string]JournalEntry)
+	for _, entry := range entries {
+		latest[entry.ID] = entry
+	}
+	cutoff := now.Add(-retention)
+	var due []JournalEntry
+	for _, entry := range latest {
+		if entry.Action == "trash" && entry.Time.Before(cutoff) {
+			due = append(due, entry)
+		}
+	}
+
+	if len(due) == 0 {
+		fmt.Fprintf(display, "No emails have been in the Trash for longer than %s\n", opts.PurgeAfter)
+		return nil
+	}
+
+	// Emails restored outside this tool, e.g. in Gmail itself, have no untrash
+	// entry in the journal, so only those still in the Trash are purged
+	inTrash, err := listLabelIds(ctx, srv, "TRASH", "")
+	if err != nil {
+		return fmt.Errorf("could not list the Trash: %w", err)
+	}
+	stillTrashed := make(map[string]bool)
+	for _, id := range inTrash {
+		stillTrashed[id] = true
+	}
+	var ids []string
+	senders := make(map[string]string)
+	for _, entry := range due {
+		if stillTrashed[entry.ID] {
+			ids = append(ids, entry.ID)
+			senders[entry.ID] = entry.Sender
+		}
+	}
+	if restored := len(due) - len(ids); restored > 0 {
+		logger.Info("Skipping emails which are no longer in the Trash", "count", restored)
+	}
+	if len(ids) == 0 {
+		fmt.Fprintf(display, "None of the emails trashed more than %s ago are still in the Trash\n", opts.PurgeAfter)
+		return nil
+	}
+
+	fmt.Fprintf(display, "%d emails have been in the Trash for longer than %s\n", len(ids), opts.PurgeAfter)
+	if !confirmPermanent() {
+		return nil
+	}
+	deleted := deletePermanently(ctx, srv, "Purging trash", ids)
+
+	// The journal records who sent each email, so entries are written a sender at a time
+	bySender := make(map[string][]string)
+	for _, id := range deleted {
+		bySender[senders[id]] = append(bySender[senders[id]], id)
+	}
+	for sender, senderIDs := range bySender {
+		appendJournal("delete", sender, senderIDs)
+	}
+
+	fmt.Fprintf(display, "Permanently deleted %d emails\n", len(deleted))
+	return nil
+}
+
+// Mention what is already in the Trash before offering new deletions, as
+// emptying it frees storage straight away. Only the label's totals are
+// fetched, so this is cheap enough to do at the start of every run
+func remindAboutTrash(ctx context.Context, srv *gmail.Service) {
+	label, err := callAPI(ctx, "labels.get", srv.Users.Labels.Get("me", "TRASH"))
+	if err != nil {
+		logger.Debug("Could not get the Trash label", "err", err)
+		return
+	}
+	if label.MessagesTotal > 0 {
+		fmt.Fprintf(display, "The Trash already holds %d emails. Run the trash command to see how much storage emptying it would free\n", label.MessagesTotal)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Handles the 'undo [run]' command, which moves every email trashed by the
+// given run of the tool back out of the Trash. With no run given, the most
+// recent run which trashed anything is undone
+func runUndoCommand(ctx context.Context, srv *gmail.Service, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: undo [run]")
+	}
+
+	entries, err := readJournal()
+	if err != nil {
+		return err
+	}
+
+	// Only messages which are still in the Trash can be restored, so skip
+	// any which were later purged or already restored
+	latest := make(map[string]JournalEntry)
+	for _, entry := range entries {
+		latest[entry.ID] = entry
+	}
+
+	run := ""
+	if len(args) == 1 {
+		run = args[0]
+	} else {
+		for _, entry := range entries {
+			if entry.Action == "trash" && latest[entry.ID].Action == "trash" {
+				run = entry.Run
+			}
+		}
+		if run == "" {
+			fmt.Fprintf(display, "There are no trashed emails to restore\n")
+			return nil
+		}
+	}
+
+	var due []JournalEntry
+	for _, entry := range entries {
+		if entry.Run == run && entry.Action == "trash" && latest[entry.ID].Action == "trash" {
+			due = append(due, entry)
+		}
+	}
+	if len(due) == 0 {
+		return fmt.Errorf("no emails trashed by run %s are left to restore", run)
+	}
+
+	fmt.Fprintf(display, "Restoring %d emails trashed by run %s\n", len(due), run)
+	defer startWork()()
+	restored := 0
+	for _, entry := range due {
+		if interrupted() {
+			break
+		}
+		if _, err := callWriteAPI(ctx, "messages.untrash", srv.Users.Messages.Untrash("me", entry.ID)); err != nil {
+			logger.Warn("Failed to restore message", "id", entry.ID, "err", err)
+			failedMessages++
+			continue
+		}
+		appendJournal("untrash", entry.Sender, []string{entry.ID})
+		restored++
+	}
+
+	fmt.Fprintf(display, "Restored %s emails\n", colorize(colorGreen, strconv.Itoa(restored)))
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Multipliers for the size suffixes accepted by parseSize
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// Parse a human readable size such as "15G", "10M" or "512K" into bytes.
+// A number with no suffix is taken to be in bytes
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "B")
+	if str == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(str, unit.suffix) {
+			multiplier = unit.bytes
+			str = strings.TrimSuffix(str, unit.suffix)
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// Format a number of bytes in a human readable form, e.g. "1.5 GB"
+func formatSize(bytes int64) string {
+	for _, unit := range sizeUnits[:len(sizeUnits)-1] {
+		if bytes >= unit.bytes {
+			return fmt.Sprintf("%.1f %sB", float64(bytes)/float64(unit.bytes), unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", bytes)
+}
+
+// Parse an age such as "30d", "6w", "18mo" or "2y" into a duration. Go
+// duration strings like "12h" are also accepted. Months are taken to be
+// 30 days and years 365 days
+func parseAge(s string) (time.Duration, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		days   float64
+	}{
+		{"mo", 30},
+		{"d", 1},
+		{"w", 7},
+		{"y", 365},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(str, unit.suffix) {
+			value, err := strconv.ParseFloat(strings.TrimSuffix(str, unit.suffix), 64)
+			if err != nil || value < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(value * unit.days * float64(24*time.Hour)), nil
+		}
+	}
+
+	age, err := time.ParseDuration(str)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return age, nil
+}
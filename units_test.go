@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{"512", 512, false},
+		{"2B", 2, false},
+		{"512K", 512 << 10, false},
+		{"1.5K", 1536, false},
+		{"10M", 10 << 20, false},
+		{"10mb", 10 << 20, false},
+		{" 15G ", 15 << 30, false},
+		{"1T", 1 << 40, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"B", 0, true},
+		{"-1M", 0, true},
+		{"big", 0, true},
+		{"10X", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSize(%q) error = %v, want error %t", tt.size, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		age     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * day, false},
+		{" 1D ", day, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"6w", 42 * day, false},
+		{"18mo", 540 * day, false},
+		{"2y", 730 * day, false},
+		{"12h", 12 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"", 0, true},
+		{"d", 0, true},
+		{"soon", 0, true},
+		{"-1d", 0, true},
+		{"-2h", 0, true},
+		{"30", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.age)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAge(%q) error = %v, want error %t", tt.age, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %v, want %v", tt.age, got, tt.want)
+		}
+	}
+}